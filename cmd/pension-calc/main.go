@@ -0,0 +1,189 @@
+// Command pension-calc runs calculations offline with the same engine as
+// the HTTP server.
+//
+// Usage:
+//
+//	pension-calc [flags] [request.json]                    read one CalculationRequest (stdin when omitted or "-")
+//	pension-calc [flags] -jsonl requests.jsonl -out results/  write line n to results/line-<nnnn>.json, e.g. line-0001.json
+//
+// Exit status is 0 on success, 1 on usage or I/O errors and 2 when
+// -fail-on-failure is set and a calculation outcome is FAILURE.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+const exitFailureOutcome = 2
+
+type options struct {
+	pretty        bool
+	endSituation  bool
+	failOnFailure bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with args and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pension-calc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	fs.BoolVar(&opts.pretty, "pretty", false, "indent JSON output")
+	fs.BoolVar(&opts.endSituation, "end-situation", false, "print only calculation_result.end_situation")
+	fs.BoolVar(&opts.failOnFailure, "fail-on-failure", false, "exit with status 2 when a calculation outcome is FAILURE")
+	jsonl := fs.String("jsonl", "", "process every line of this NDJSON file of CalculationRequests")
+	outDir := fs.String("out", "", "directory for per-line results (required with -jsonl)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	// As for the server, SCHEME_REGISTRY_DIR takes precedence over
+	// SCHEME_REGISTRY_URL
+	if dir := os.Getenv("SCHEME_REGISTRY_DIR"); dir != "" {
+		schemes, err := schemeregistry.OpenDir(dir)
+		if err != nil {
+			fmt.Fprintln(stderr, "pension-calc: scheme registry:", err)
+			return 1
+		}
+		defer schemes.Close()
+		schemeregistry.SetDefault(schemes)
+	}
+
+	var err error
+	failed := false
+	switch {
+	case *jsonl != "":
+		if *outDir == "" {
+			fmt.Fprintln(stderr, "pension-calc: -out is required with -jsonl")
+			return 1
+		}
+		failed, err = runJSONL(*jsonl, *outDir, stderr, opts)
+	case fs.NArg() > 1:
+		fmt.Fprintln(stderr, "pension-calc: at most one request file may be given")
+		return 1
+	default:
+		failed, err = runSingle(fs.Arg(0), stdin, stdout, opts)
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "pension-calc:", err)
+		return 1
+	}
+	if failed && opts.failOnFailure {
+		return exitFailureOutcome
+	}
+	return 0
+}
+
+func runSingle(path string, stdin io.Reader, w io.Writer, opts options) (bool, error) {
+	var (
+		in  []byte
+		err error
+	)
+	if path == "" || path == "-" {
+		in, err = io.ReadAll(stdin)
+	} else {
+		in, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	out, failed, err := calculate(in, opts)
+	if err != nil {
+		return false, err
+	}
+	_, err = w.Write(out)
+	return failed, err
+}
+
+// runJSONL writes the result of line n to <outDir>/line-<nnnn>.json, n
+// zero-padded to four digits. Lines that cannot be calculated are reported
+// on stderr and make the run fail.
+func runJSONL(path, outDir string, stderr io.Writer, opts options) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return false, err
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	failed, badLines := false, 0
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		out, lineFailed, err := calculate(line, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "pension-calc: line %d: %v\n", n, err)
+			badLines++
+			continue
+		}
+		failed = failed || lineFailed
+		name := filepath.Join(outDir, fmt.Sprintf("line-%04d.json", n))
+		if err := os.WriteFile(name, out, 0o644); err != nil {
+			return failed, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return failed, err
+	}
+	if badLines > 0 {
+		return failed, fmt.Errorf("%d line(s) could not be calculated", badLines)
+	}
+	return failed, nil
+}
+
+// calculate runs one encoded CalculationRequest and returns the encoded
+// output and whether the outcome was FAILURE.
+func calculate(in []byte, opts options) ([]byte, bool, error) {
+	var req model.CalculationRequest
+	if err := json.Unmarshal(in, &req); err != nil {
+		return nil, false, fmt.Errorf("invalid request: %w", err)
+	}
+	if len(req.CalculationInstructions.Mutations) == 0 {
+		return nil, false, errors.New("at least one mutation is required")
+	}
+
+	resp := engine.Process(&req)
+	failed := resp.CalculationMetadata.CalculationOutcome == model.OutcomeFailure
+
+	var v interface{} = resp
+	if opts.endSituation {
+		v = resp.CalculationResult.EndSituation
+	}
+	var (
+		out []byte
+		err error
+	)
+	if opts.pretty {
+		out, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		out, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, failed, err
+	}
+	return append(out, '\n'), failed, nil
+}
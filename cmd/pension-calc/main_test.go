@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+)
+
+const (
+	successRequest = `{"tenant_id":"test","calculation_instructions":{"mutations":[{"mutation_id":"a1111111-1111-1111-1111-111111111111","mutation_definition_name":"create_dossier","mutation_type":"DOSSIER_CREATION","actual_at":"2020-01-01","mutation_properties":{"dossier_id":"d2222222-2222-2222-2222-222222222222","person_id":"p3333333-3333-3333-3333-333333333333","name":"Jane Doe","birth_date":"1960-06-15"}}]}}`
+	// add_policy without a dossier fails with DOSSIER_NOT_FOUND
+	failureRequest = `{"tenant_id":"test","calculation_instructions":{"mutations":[{"mutation_id":"b1111111-1111-1111-1111-111111111111","mutation_definition_name":"add_policy","mutation_type":"DOSSIER","actual_at":"2020-01-01","mutation_properties":{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}}]}}`
+)
+
+func TestCalculate(t *testing.T) {
+	out, failed, err := calculate([]byte(successRequest), options{})
+	if err != nil || failed {
+		t.Fatalf("expected a successful calculation, got failed=%v, err=%v", failed, err)
+	}
+	var resp model.CalculationResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if d := resp.CalculationResult.EndSituation.Situation.Dossier; d == nil || d.Status != model.StatusActive {
+		t.Fatalf("expected an ACTIVE dossier, got %s", out)
+	}
+
+	out, _, err = calculate([]byte(successRequest), options{endSituation: true, pretty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var end model.SituationEnvelope
+	if err := json.Unmarshal(out, &end); err != nil || end.Situation.Dossier == nil || !bytes.Contains(out, []byte("\n  ")) {
+		t.Fatalf("expected an indented end situation, got %s", out)
+	}
+
+	if _, failed, err := calculate([]byte(failureRequest), options{}); err != nil || !failed {
+		t.Fatalf("expected a FAILURE outcome, got failed=%v, err=%v", failed, err)
+	}
+	for _, in := range []string{`{"tenant_id":`, `{"tenant_id":"test","calculation_instructions":{"mutations":[]}}`} {
+		if _, _, err := calculate([]byte(in), options{}); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		args  []string
+		stdin string
+		want  int
+	}{
+		{"success", nil, successRequest, 0},
+		{"failure outcome", nil, failureRequest, 0},
+		{"failure outcome with -fail-on-failure", []string{"-fail-on-failure"}, failureRequest, exitFailureOutcome},
+		{"success with -fail-on-failure", []string{"-fail-on-failure", "-"}, successRequest, 0},
+		{"invalid request", nil, `{`, 1},
+		{"two request files", []string{"a.json", "b.json"}, "", 1},
+		{"-jsonl without -out", []string{"-jsonl", "requests.jsonl"}, "", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if got := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr); got != tc.want {
+				t.Fatalf("expected exit status %d, got %d (stderr: %s)", tc.want, got, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunJSONL(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "requests.jsonl")
+	os.WriteFile(in, []byte(successRequest+"\n\n"+failureRequest+"\n"), 0o644)
+	out := filepath.Join(dir, "results")
+
+	var stdout, stderr bytes.Buffer
+	if got := run([]string{"-fail-on-failure", "-jsonl", in, "-out", out}, nil, &stdout, &stderr); got != exitFailureOutcome {
+		t.Fatalf("expected exit status %d, got %d (stderr: %s)", exitFailureOutcome, got, stderr.String())
+	}
+	for _, name := range []string{"line-0001.json", "line-0003.json"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "line-0002.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no result for the blank line, got %v", err)
+	}
+
+	os.WriteFile(in, []byte(successRequest+"\n{\n"), 0o644)
+	stderr.Reset()
+	if got := run([]string{"-jsonl", in, "-out", out}, nil, &stdout, &stderr); got != 1 || !strings.Contains(stderr.String(), "line 2") {
+		t.Fatalf("expected exit status 1 naming line 2, got %d (stderr: %s)", got, stderr.String())
+	}
+}
@@ -6,14 +6,39 @@ import (
 
 	"pension-engine/internal/model"
 	"pension-engine/internal/mutations"
+	"pension-engine/internal/schema"
+	"pension-engine/internal/schemeregistry"
 )
 
 var emptyPatch = []byte("[]")
 
+// init checks that every registered mutation has a schema in
+// mutation-definitions; schema.Validate lets the properties of a mutation
+// without one through unchecked.
+func init() {
+	for _, name := range mutations.Names() {
+		if !schema.Has(name) {
+			panic("engine: mutation " + name + " has no schema in mutation-definitions")
+		}
+	}
+}
+
+// Process runs the calculation with the scheme provider configured through
+// the environment.
 func Process(req *model.CalculationRequest) *model.CalculationResponse {
+	return ProcessWith(req, schemeregistry.Default())
+}
+
+// ProcessWith runs the calculation with scheme parameters from provider.
+// Each scheme is read from provider at most once per calculation.
+func ProcessWith(req *model.CalculationRequest, provider schemeregistry.SchemeProvider) *model.CalculationResponse {
 	startTime := time.Now().UTC()
+	schemes := schemeregistry.NewSnapshot(provider)
 
-	state := &model.Situation{Dossier: nil}
+	initial := model.InitialSituation{ActualAt: req.CalculationInstructions.Mutations[0].ActualAt}
+	if req.CalculationInstructions.InitialSituation != nil {
+		initial = *req.CalculationInstructions.InitialSituation
+	}
 
 	mutCount := len(req.CalculationInstructions.Mutations)
 	allMessages := make([]model.CalculationMessage, 0, mutCount*2)
@@ -25,8 +50,31 @@ func Process(req *model.CalculationRequest) *model.CalculationResponse {
 	lastMutationIndex := 0
 	lastActualAt := req.CalculationInstructions.Mutations[0].ActualAt
 	appliedAny := false
+	initialRejected := false
+
+	// A supplied situation is copied so the caller's value is never mutated.
+	state := &model.Situation{Dossier: nil}
+	if req.CalculationInstructions.InitialSituation != nil {
+		if msgs := validateInitialSituation(&initial); len(msgs) > 0 {
+			for j := range msgs {
+				msgs[j].ID = len(allMessages)
+				allMessages = append(allMessages, msgs[j])
+			}
+			outcome = model.OutcomeFailure
+			hasCritical = true
+			initialRejected = true
+		} else {
+			*state = initial.Situation.Clone()
+			if state.Dossier != nil {
+				seedPolicySeq(state.Dossier, initial.PolicySeq)
+			}
+		}
+	}
 
 	for i, mut := range req.CalculationInstructions.Mutations {
+		if hasCritical {
+			break
+		}
 		handler, ok := mutations.Get(mut.MutationDefinitionName)
 		if !ok {
 			msgID := len(allMessages)
@@ -47,7 +95,33 @@ func Process(req *model.CalculationRequest) *model.CalculationResponse {
 			break
 		}
 
-		msgs, critical, fwdPatch, bwdPatch := handler.Execute(state, &mut)
+		var msgs []model.CalculationMessage
+		var critical bool
+		var fwdPatch, bwdPatch []byte
+		if violations := schema.Validate(mut.MutationDefinitionName, mut.MutationProperties); len(violations) > 0 {
+			// Schema violations stop the mutation before its handler sees zero values.
+			msgs = invalidPropertiesMessages(violations)
+			critical, fwdPatch, bwdPatch = true, emptyPatch, emptyPatch
+		} else if msgs = checkLifecycle(state, mut.MutationDefinitionName); len(msgs) > 0 {
+			// A mutation the dossier's status does not allow is rejected the same way.
+			critical, fwdPatch, bwdPatch = true, emptyPatch, emptyPatch
+		} else {
+			// Strict mode undoes a mutation that ran on default parameters.
+			var before model.Situation
+			strict := schemeregistry.Strict()
+			if strict {
+				before = state.Clone()
+			}
+			msgs, critical, fwdPatch, bwdPatch = handler.Execute(state, &mut, schemes)
+			if errs := schemes.Failures(); len(errs) > 0 {
+				if strict {
+					*state = before
+					msgs, critical, fwdPatch, bwdPatch = unavailableMessages(errs, model.LevelCritical), true, emptyPatch, emptyPatch
+				} else {
+					msgs = append(msgs, unavailableMessages(errs, model.LevelWarning)...)
+				}
+			}
+		}
 		if critical {
 			hasCritical = true
 		}
@@ -87,8 +161,16 @@ func Process(req *model.CalculationRequest) *model.CalculationResponse {
 		Situation:     *state,
 	}
 
-	if hasCritical && !appliedAny {
-		endSituation.Situation = model.Situation{Dossier: nil}
+	switch {
+	case initialRejected:
+		// No mutation ran, and the rejected situation is no result of one
+		endSituation = model.SituationEnvelope{MutationIndex: -1, ActualAt: initial.ActualAt}
+	case hasCritical && !appliedAny:
+		endSituation.Situation = initial.Situation
+	}
+	// Without applied mutations state still holds the seeded initial dossier
+	if state.Dossier != nil {
+		endSituation.PolicySeq = state.Dossier.PolicySeq
 	}
 
 	endTime := time.Now().UTC()
@@ -101,19 +183,48 @@ func Process(req *model.CalculationRequest) *model.CalculationResponse {
 			CalculationCompletedAt: endTime.Format(time.RFC3339),
 			CalculationDurationMs:  endTime.Sub(startTime).Milliseconds(),
 			CalculationOutcome:     outcome,
+			SchemeRegistryVersion:  schemeregistry.Version(schemes),
 		},
 		CalculationResult: model.CalculationResult{
-			Messages:  allMessages,
-			Mutations: processedMutations,
-			EndSituation: endSituation,
-			InitialSituation: model.InitialSituation{
-				ActualAt:  req.CalculationInstructions.Mutations[0].ActualAt,
-				Situation: model.Situation{Dossier: nil},
-			},
+			Messages:         allMessages,
+			Mutations:        processedMutations,
+			EndSituation:     endSituation,
+			InitialSituation: initial,
 		},
 	}
 }
 
+// unavailableMessages reports the schemes the provider could not load. At
+// WARNING level the calculation continued with their default parameters.
+func unavailableMessages(errs []error, level string) []model.CalculationMessage {
+	msgs := make([]model.CalculationMessage, len(errs))
+	for i, err := range errs {
+		text := "Scheme registry could not provide " + err.Error()
+		if level == model.LevelWarning {
+			text += "; default parameters were used"
+		}
+		msgs[i] = model.CalculationMessage{
+			Level:   level,
+			Code:    "SCHEME_REGISTRY_UNAVAILABLE",
+			Message: text,
+		}
+	}
+	return msgs
+}
+
+func invalidPropertiesMessages(violations []schema.Violation) []model.CalculationMessage {
+	msgs := make([]model.CalculationMessage, len(violations))
+	for i, v := range violations {
+		// The pointer is relative to mutation_properties, e.g. "mutation_properties/retirement_date is required".
+		msgs[i] = model.CalculationMessage{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_MUTATION_PROPERTIES",
+			Message: "mutation_properties" + v.Pointer + " " + v.Message,
+		}
+	}
+	return msgs
+}
+
 // fastUUID generates a UUID v4 string using math/rand instead of crypto/rand.
 func fastUUID() string {
 	r1 := rand.Uint64()
@@ -2,10 +2,20 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"pension-engine/internal/model"
+	"pension-engine/internal/mutations"
+	"pension-engine/internal/schemeregistry"
 )
 
 func assertFloat(t *testing.T, name string, got, want float64) {
@@ -285,54 +295,1331 @@ func TestCalculateRetirementNotEligible(t *testing.T) {
 	}
 }
 
+// useSchemes serves the given scheme payloads from a test registry for the
+// duration of the test.
+func useSchemes(t *testing.T, schemes map[string]string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := schemes[r.URL.Path[len("/schemes/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	schemeregistry.SetURL(srv.URL)
+	t.Cleanup(func() {
+		schemeregistry.SetURL("")
+		srv.Close()
+	})
+}
+
+func serviceYears(start, end string) float64 {
+	s, _ := time.Parse("2006-01-02", start)
+	e, _ := time.Parse("2006-01-02", end)
+	return e.Sub(s).Hours() / 24 / 365.25
+}
+
+func TestCalculateRetirementEarlyReduction(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"min_retirement_age":60,"early_retirement_reduction_per_month":0.005}`,
+	})
+	// Born 1960-06-15, retiring 12 months before 65 → factor 1 - 12*0.005 = 0.94
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2024-06-15"),
+	))
+
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %s: %+v", resp.CalculationMetadata.CalculationOutcome, resp.CalculationResult.Messages)
+	}
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "EARLY_RETIREMENT_REDUCTION" || msgs[0].Level != model.LevelWarning {
+		t.Fatalf("expected one EARLY_RETIREMENT_REDUCTION warning, got %+v", msgs)
+	}
+	want := 50000 * serviceYears("2000-01-01", "2024-06-15") * 0.02 * 0.94
+	assertFloat(t, "attainable_pension", *resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].AttainablePension, want)
+}
+
+func TestCalculateRetirementBelowSchemeMinimumAge(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"min_retirement_age":60,"early_retirement_reduction_per_month":0.005}`,
+	})
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2020-06-14"),
+	))
+
+	if resp.CalculationMetadata.CalculationOutcome != "FAILURE" || resp.CalculationResult.Messages[0].Code != "NOT_ELIGIBLE" {
+		t.Fatalf("expected NOT_ELIGIBLE, got %+v", resp.CalculationResult.Messages)
+	}
+}
+
+func TestCalculateRetirementLateIncrease(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"late_retirement_increase_per_month":0.006}`,
+	})
+	// Retiring 6 months after 65 → factor 1 + 6*0.006 = 1.036
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-12-15"),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "LATE_RETIREMENT_INCREASE" {
+		t.Fatalf("expected one LATE_RETIREMENT_INCREASE warning, got %+v", msgs)
+	}
+	want := 50000 * serviceYears("2000-01-01", "2025-12-15") * 0.02 * 1.036
+	assertFloat(t, "attainable_pension", *resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].AttainablePension, want)
+}
+
 func TestCalculateRetirementNoDossier(t *testing.T) {
 	resp := Process(makeReq("test", retirementMut("2025-01-01")))
 
 	if resp.CalculationMetadata.CalculationOutcome != "FAILURE" {
-		t.Fatalf("expected FAILURE")
+		t.Fatalf("expected FAILURE")
+	}
+	if resp.CalculationResult.Messages[0].Code != "DOSSIER_NOT_FOUND" {
+		t.Fatalf("expected DOSSIER_NOT_FOUND")
+	}
+}
+
+// --- Full flow (README example) ---
+
+func TestFullFlowReadmeExample(t *testing.T) {
+	resp := Process(makeReq("tenant-001",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		indexationMut(0.03, "", ""),
+	))
+
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS")
+	}
+	if resp.CalculationMetadata.TenantID != "tenant-001" {
+		t.Fatalf("expected tenant-001")
+	}
+	if len(resp.CalculationResult.Messages) != 0 {
+		t.Fatalf("expected 0 messages, got %d", len(resp.CalculationResult.Messages))
+	}
+	if len(resp.CalculationResult.Mutations) != 3 {
+		t.Fatalf("expected 3 mutations, got %d", len(resp.CalculationResult.Mutations))
+	}
+
+	end := resp.CalculationResult.EndSituation
+	if end.MutationIndex != 2 {
+		t.Fatalf("expected mutation_index 2, got %d", end.MutationIndex)
+	}
+	if end.ActualAt != "2021-01-01" {
+		t.Fatalf("expected actual_at 2021-01-01, got %s", end.ActualAt)
+	}
+
+	policies := end.Situation.Dossier.Policies
+	if len(policies) != 1 {
+		t.Fatalf("expected 1 policy, got %d", len(policies))
+	}
+	assertFloat(t, "salary after indexation", policies[0].Salary, 51500)
+	if policies[0].PolicyID != dossierID+"-1" {
+		t.Fatalf("expected policy_id %s-1", dossierID)
+	}
+}
+
+// --- mutation_properties validation ---
+
+func TestMissingRequiredPropertyRejected(t *testing.T) {
+	mut := retirementMut("2025-01-01")
+	mut.MutationProperties = json.RawMessage(`{}`)
+	resp := Process(makeReq("test", createDossierMut(), addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0), mut))
+
+	if resp.CalculationMetadata.CalculationOutcome != "FAILURE" {
+		t.Fatalf("expected FAILURE, got %s", resp.CalculationMetadata.CalculationOutcome)
+	}
+	msg := resp.CalculationResult.Messages[0]
+	if msg.Code != "INVALID_MUTATION_PROPERTIES" || msg.Message != "mutation_properties/retirement_date is required" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if resp.CalculationResult.EndSituation.MutationIndex != 1 {
+		t.Fatalf("expected end situation after mutation 1, got %d", resp.CalculationResult.EndSituation.MutationIndex)
+	}
+}
+
+func TestMalformedPropertiesRejected(t *testing.T) {
+	mut := retirementMut("2025-01-01")
+	mut.MutationProperties = json.RawMessage(`{"retirement_date":`)
+	resp := Process(makeReq("test", createDossierMut(), mut))
+
+	msg := resp.CalculationResult.Messages[0]
+	if msg.Code != "INVALID_MUTATION_PROPERTIES" || msg.Message != "mutation_properties is not valid JSON" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestMisspelledPropertyRejected(t *testing.T) {
+	mut := indexationMut(0.03, "", "")
+	mut.MutationProperties = json.RawMessage(`{"percentge":0.03}`)
+	resp := Process(makeReq("test", createDossierMut(), addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0), mut))
+
+	var pointers []string
+	for _, m := range resp.CalculationResult.Messages {
+		if m.Code != "INVALID_MUTATION_PROPERTIES" {
+			t.Fatalf("unexpected code %s", m.Code)
+		}
+		pointers = append(pointers, m.Message)
+	}
+	want := []string{"mutation_properties/percentage is required", "mutation_properties/percentge is not an allowed property"}
+	if len(pointers) != len(want) || pointers[0] != want[0] || pointers[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, pointers)
+	}
+	assertFloat(t, "salary unchanged", resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].Salary, 50000)
+}
+
+func TestInvalidDateFormatRejected(t *testing.T) {
+	resp := Process(makeReq("test", createDossierMut(), addPolicyMut("SCHEME-A", "2000-13-01", 50000, 1.0)))
+
+	msg := resp.CalculationResult.Messages[0]
+	if msg.Code != "INVALID_MUTATION_PROPERTIES" || msg.Message != "mutation_properties/employment_start_date must be a valid date" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+// --- terminate_employment ---
+
+func TestTerminateEmploymentCapsProjections(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		terminateMut(dossierID+"-1", "2020-01-01"),
+		projectionMut("2019-01-01", "2022-01-01", 12),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	if policy.EmploymentEndDate == nil || *policy.EmploymentEndDate != "2020-01-01" {
+		t.Fatal("expected employment_end_date 2020-01-01")
+	}
+	capped := 50000 * serviceYears("2000-01-01", "2020-01-01") * 0.02
+	assertFloat(t, "2019 projection", policy.Projections[0].ProjectedPension, 50000*serviceYears("2000-01-01", "2019-01-01")*0.02)
+	for _, p := range policy.Projections[1:] {
+		assertFloat(t, p.Date+" projection", p.ProjectedPension, capped)
+	}
+}
+
+func TestTerminateEmploymentValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		muts []model.Mutation
+		code string
+	}{
+		{"unknown policy", []model.Mutation{terminateMut(dossierID+"-9", "2020-01-01")}, "POLICY_NOT_FOUND"},
+		{"end before start", []model.Mutation{terminateMut(dossierID+"-1", "1999-12-31")}, "INVALID_EMPLOYMENT_END_DATE"},
+		{"already terminated", []model.Mutation{terminateMut(dossierID+"-1", "2020-01-01"), terminateMut(dossierID+"-1", "2021-01-01")}, "EMPLOYMENT_ALREADY_TERMINATED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			muts := append([]model.Mutation{createDossierMut(), addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0)}, tt.muts...)
+			resp := Process(makeReq("test", muts...))
+			msgs := resp.CalculationResult.Messages
+			if len(msgs) != 1 || msgs[0].Code != tt.code || msgs[0].Level != model.LevelCritical {
+				t.Fatalf("expected CRITICAL %s, got %+v", tt.code, msgs)
+			}
+		})
+	}
+}
+
+// --- change_salary / change_part_time_factor ---
+
+func TestSalaryHistoryTimeWeighted(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		changeSalaryMut(dossierID+"-1", "2010-01-01", 60000),
+		changePartTimeMut(dossierID+"-1", "2020-01-01", 0.5),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	if len(policy.SalaryHistory) != 3 {
+		t.Fatalf("expected 3 salary segments, got %+v", policy.SalaryHistory)
+	}
+	assertFloat(t, "current salary", policy.Salary, 60000)
+	assertFloat(t, "current part_time_factor", policy.PartTimeFactor, 0.5)
+
+	want := (50000*serviceYears("2000-01-01", "2010-01-01") +
+		60000*serviceYears("2010-01-01", "2020-01-01") +
+		30000*serviceYears("2020-01-01", "2025-06-15")) * 0.02
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, want)
+}
+
+func TestSalaryHistoryIndexedAsWhole(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		changeSalaryMut(dossierID+"-1", "2010-01-01", 60000),
+		indexationMut(0.1, "", ""),
+	))
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	assertFloat(t, "salary", policy.Salary, 66000)
+	assertFloat(t, "first segment", policy.SalaryHistory[0].Salary, 55000)
+	assertFloat(t, "last segment", policy.SalaryHistory[1].Salary, 66000)
+}
+
+func TestChangeSalaryOutsideEmployment(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		changeSalaryMut(dossierID+"-1", "1999-01-01", 60000),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "INVALID_EFFECTIVE_DATE" {
+		t.Fatalf("expected INVALID_EFFECTIVE_DATE, got %+v", msgs)
+	}
+}
+
+// --- remove_policy ---
+
+func TestRemovePolicyNeverReusesID(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8),
+		removePolicyMut(dossierID+"-2"),
+		addPolicyMut("SCHEME-C", "2015-01-01", 40000, 1.0),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	if len(policies) != 2 || policies[0].PolicyID != dossierID+"-1" || policies[1].PolicyID != dossierID+"-3" {
+		t.Fatalf("expected policies -1 and -3, got %+v", policies)
+	}
+}
+
+func TestRemovePolicyBackwardPatchRoundTrip(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8),
+		addPolicyMut("SCHEME-C", "2015-01-01", 40000, 1.0),
+		removePolicyMut(dossierID+"-1"),
+	))
+	result := &resp.CalculationResult
+
+	// Undoing the removal from the end situation must restore the policy at
+	// its original index, with the later policies shifted back up.
+	before, err := Reconstruct(result, 3, DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := before.Situation.Dossier.Policies
+	if len(policies) != 3 {
+		t.Fatalf("expected 3 policies before removal, got %d", len(policies))
+	}
+	for i, want := range []string{"-1", "-2", "-3"} {
+		if policies[i].PolicyID != dossierID+want {
+			t.Fatalf("policy %d: expected %s%s, got %s", i, dossierID, want, policies[i].PolicyID)
+		}
+	}
+	fwd, _ := Reconstruct(result, 3, DirectionForward)
+	f, _ := json.Marshal(fwd)
+	b, _ := json.Marshal(before)
+	if string(f) != string(b) {
+		t.Fatalf("forward and backward differ\nforward:  %s\nbackward: %s", f, b)
+	}
+}
+
+func TestRemovePolicyRetiredRejected(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "1980-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		removePolicyMut(dossierID+"-1"),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "DOSSIER_RETIRED" {
+		t.Fatalf("expected DOSSIER_RETIRED, got %+v", msgs)
+	}
+	if n := len(resp.CalculationResult.EndSituation.Situation.Dossier.Policies); n != 1 {
+		t.Fatalf("expected the policy to be kept, got %d policies", n)
+	}
+}
+
+// --- add_partner / remove_partner ---
+
+func TestSurvivorPensionWithPartner(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	dossier := resp.CalculationResult.EndSituation.Situation.Dossier
+	if len(dossier.Persons) != 2 || dossier.Persons[1].Role != "PARTNER" {
+		t.Fatalf("expected a PARTNER person, got %+v", dossier.Persons)
+	}
+	policy := dossier.Policies[0]
+	if policy.SurvivorPension == nil {
+		t.Fatal("expected survivor_pension to be set")
+	}
+	assertFloat(t, "survivor_pension", *policy.SurvivorPension, *policy.AttainablePension*0.7)
+}
+
+func TestSurvivorPensionRequiresPartner(t *testing.T) {
+	partnerID := "p4444444-4444-4444-4444-444444444444"
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut(partnerID, "1962-03-01"),
+		removePartnerMut(partnerID),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+
+	dossier := resp.CalculationResult.EndSituation.Situation.Dossier
+	if len(dossier.Persons) != 1 || dossier.Policies[0].SurvivorPension != nil {
+		t.Fatalf("expected no partner and no survivor_pension, got %+v", dossier)
+	}
+}
+
+func TestAddPartnerTwiceRejected(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		addPartnerMut("p5555555-5555-5555-5555-555555555555", "1970-01-01"),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "PARTNER_ALREADY_EXISTS" {
+		t.Fatalf("expected PARTNER_ALREADY_EXISTS, got %+v", msgs)
+	}
+}
+
+// --- register_death / lifecycle ---
+
+func TestRegisterDeathActivePaysSurvivorBenefit(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		projectionMut("2021-01-01", "2025-01-01", 12),
+		registerDeathMut("2022-01-01"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	dossier := resp.CalculationResult.EndSituation.Situation.Dossier
+	if dossier.Status != model.StatusDeceased {
+		t.Fatalf("expected DECEASED, got %s", dossier.Status)
+	}
+	if d := dossier.Persons[0].DateOfDeath; d == nil || *d != "2022-01-01" {
+		t.Fatal("expected date_of_death on the participant")
+	}
+	policy := dossier.Policies[0]
+	if policy.EmploymentEndDate == nil || *policy.EmploymentEndDate != "2022-01-01" || policy.Projections != nil {
+		t.Fatalf("expected accrual and projections to stop, got %+v", policy)
+	}
+	want := 50000 * serviceYears("2000-01-01", "2022-01-01") * 0.02 * 0.7
+	assertFloat(t, "survivor_pension", *policy.SurvivorPension, want)
+	if b := dossier.Persons[1].SurvivorBenefit; b == nil {
+		t.Fatal("expected survivor_benefit on the partner")
+	} else {
+		assertFloat(t, "survivor_benefit", *b, want)
+	}
+}
+
+func TestRegisterDeathRetiredKeepsSurvivorPension(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		registerDeathMut("2026-01-01"),
+	))
+
+	dossier := resp.CalculationResult.EndSituation.Situation.Dossier
+	policy := dossier.Policies[0]
+	assertFloat(t, "survivor_pension", *policy.SurvivorPension, *policy.AttainablePension*0.7)
+	assertFloat(t, "survivor_benefit", *dossier.Persons[1].SurvivorBenefit, *policy.SurvivorPension)
+	if policy.EmploymentEndDate != nil {
+		t.Fatalf("expected no employment_end_date after retirement, got %s", *policy.EmploymentEndDate)
+	}
+}
+
+func TestRegisterDeathRejectsDateBeforeRetirement(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		registerDeathMut("2024-01-01"),
+	))
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "INVALID_DATE_OF_DEATH" || msgs[0].Level != model.LevelCritical {
+		t.Fatalf("expected CRITICAL INVALID_DATE_OF_DEATH, got %+v", msgs)
+	}
+	if d := resp.CalculationResult.EndSituation.Situation.Dossier; d.Status != model.StatusRetired {
+		t.Fatalf("expected the dossier to stay RETIRED, got %s", d.Status)
+	}
+}
+
+func TestDeceasedDossierRejectsMutations(t *testing.T) {
+	for _, mut := range []model.Mutation{
+		addPolicyMut("SCHEME-B", "2010-01-01", 60000, 1.0),
+		indexationMut(0.03, "", ""),
+		retirementMut("2025-06-15"),
+		registerDeathMut("2022-01-01"),
+	} {
+		t.Run(mut.MutationDefinitionName, func(t *testing.T) {
+			resp := Process(makeReq("test",
+				createDossierMut(),
+				addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+				registerDeathMut("2021-06-01"),
+				mut,
+			))
+			msgs := resp.CalculationResult.Messages
+			if len(msgs) != 1 || msgs[0].Code != "DOSSIER_DECEASED" || msgs[0].Level != model.LevelCritical {
+				t.Fatalf("expected CRITICAL DOSSIER_DECEASED, got %+v", msgs)
+			}
+			if resp.CalculationResult.EndSituation.MutationIndex != 2 {
+				t.Fatalf("expected end situation after register_death, got index %d", resp.CalculationResult.EndSituation.MutationIndex)
+			}
+		})
+	}
+}
+
+// --- divorce_split ---
+
+func TestDivorceSplitRecordsEntitlement(t *testing.T) {
+	partnerID := "p4444444-4444-4444-4444-444444444444"
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut(partnerID, "1962-03-01"),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		divorceSplitMut(partnerID, "1995-01-01", "2010-01-01", 0.5),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	dossier := resp.CalculationResult.EndSituation.Situation.Dossier
+	ex := dossier.Persons[1]
+	if ex.Role != "EX_PARTNER" || len(ex.PensionEntitlements) != 1 {
+		t.Fatalf("expected an EX_PARTNER with one entitlement, got %+v", ex)
+	}
+	// Only the service within the marriage counts
+	entitlement := 50000 * serviceYears("2000-01-01", "2010-01-01") * 0.02 * 0.5
+	assertFloat(t, "entitlement", ex.PensionEntitlements[0].Amount, entitlement)
+
+	policy := dossier.Policies[0]
+	full := 50000 * serviceYears("2000-01-01", "2025-06-15") * 0.02
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, full-entitlement)
+	if policy.SurvivorPension != nil {
+		t.Fatal("expected no survivor_pension after the divorce")
+	}
+}
+
+func TestDivorceSplitValidation(t *testing.T) {
+	partnerID := "p4444444-4444-4444-4444-444444444444"
+	cases := []struct {
+		name string
+		mut  model.Mutation
+		code string
+	}{
+		{"unknown partner", divorceSplitMut("p5555555-5555-5555-5555-555555555555", "1995-01-01", "2010-01-01", 0.5), "PARTNER_NOT_FOUND"},
+		{"participant", divorceSplitMut(personID, "1995-01-01", "2010-01-01", 0.5), "PARTNER_NOT_FOUND"},
+		{"end before start", divorceSplitMut(partnerID, "2010-01-01", "1995-01-01", 0.5), "INVALID_MARRIAGE_PERIOD"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := Process(makeReq("test",
+				createDossierMut(),
+				addPartnerMut(partnerID, "1962-03-01"),
+				addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+				tc.mut,
+			))
+			msgs := resp.CalculationResult.Messages
+			if len(msgs) != 1 || msgs[0].Code != tc.code {
+				t.Fatalf("expected %s, got %+v", tc.code, msgs)
+			}
+		})
+	}
+}
+
+// --- commute_pension ---
+
+func TestCommutePensionWithRequestFactor(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		commuteMut(0.2, 15),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	full := 50000 * serviceYears("2000-01-01", "2025-06-15") * 0.02
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, full*0.8)
+	assertFloat(t, "commuted_pension", *policy.CommutedPension, full*0.2)
+	assertFloat(t, "lump_sum", *policy.LumpSum, full*0.2*15)
+	// The survivor pension is not reduced by the commutation
+	assertFloat(t, "survivor_pension", *policy.SurvivorPension, full*0.7)
+}
+
+func TestCommutePensionSchemeFactorAndMaximum(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"annuity_factor":12,"max_commutation_percentage":0.3}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		commuteMut(0.3, 0),
+	))
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	full := 50000 * serviceYears("2000-01-01", "2025-06-15") * 0.02
+	assertFloat(t, "lump_sum", *policy.LumpSum, full*0.3*12)
+
+	resp = Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		commuteMut(0.35, 0),
+	))
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "COMMUTATION_EXCEEDS_MAXIMUM" {
+		t.Fatalf("expected COMMUTATION_EXCEEDS_MAXIMUM, got %+v", msgs)
+	}
+}
+
+func TestCommutePensionValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		muts []model.Mutation
+		code string
+	}{
+		{"not retired", []model.Mutation{commuteMut(0.2, 15)}, "DOSSIER_ACTIVE"},
+		{"no annuity factor", []model.Mutation{retirementMut("2025-06-15"), commuteMut(0.2, 0)}, "ANNUITY_FACTOR_MISSING"},
+		{"default maximum", []model.Mutation{retirementMut("2025-06-15"), commuteMut(0.3, 15)}, "COMMUTATION_EXCEEDS_MAXIMUM"},
+		{"twice", []model.Mutation{retirementMut("2025-06-15"), commuteMut(0.1, 15), commuteMut(0.1, 15)}, "PENSION_ALREADY_COMMUTED"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			muts := append([]model.Mutation{
+				createDossierMut(),
+				addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+			}, tc.muts...)
+			msgs := Process(makeReq("test", muts...)).CalculationResult.Messages
+			if len(msgs) != 1 || msgs[0].Code != tc.code || msgs[0].Level != model.LevelCritical {
+				t.Fatalf("expected CRITICAL %s, got %+v", tc.code, msgs)
+			}
+		})
+	}
+}
+
+func TestRetirementRecalculationKeepsCommutation(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+		commuteMut(0.2, 15),
+		retirementMut("2025-06-15"),
+	))
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	full := 50000 * serviceYears("2000-01-01", "2025-06-15") * 0.02
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, full*0.8)
+}
+
+// --- defined contribution ---
+
+const dcScheme = `{"scheme_id":"SCHEME-DC","scheme_type":"DC","accrual_rate":0,"annuity_factor":20,"assumed_return_rate":0.04}`
+
+func TestDefinedContributionRetirement(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-DC": dcScheme})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-DC", "2010-01-01", 40000, 1.0),
+		contributionMut(dossierID+"-2", 100000),
+		investmentReturnMut(0.1, ""),
+		contributionMut(dossierID+"-2", 10000),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	assertFloat(t, "capital_balance", *policies[1].CapitalBalance, 120000)
+	// The DB policy keeps the pension it would have on its own
+	assertFloat(t, "db attainable_pension", *policies[0].AttainablePension, 50000*serviceYears("2000-01-01", "2025-06-15")*0.02)
+	assertFloat(t, "dc attainable_pension", *policies[1].AttainablePension, 120000.0/20)
+}
+
+func TestDefinedContributionProjection(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-DC": dcScheme})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-DC", "2010-01-01", 40000, 1.0),
+		contributionMut(dossierID+"-1", 100000),
+		projectionMut("2021-01-01", "2023-01-01", 12),
+	))
+
+	// Projections grow from the mutation's actual_at, 2021-01-01
+	projections := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].Projections
+	if len(projections) != 3 {
+		t.Fatalf("expected 3 projections, got %d", len(projections))
+	}
+	last := projections[2]
+	capital := 100000 * math.Pow(1.04, serviceYears("2021-01-01", "2023-01-01"))
+	assertFloat(t, "projected_capital", *last.ProjectedCapital, capital)
+	assertFloat(t, "projected_pension", last.ProjectedPension, capital/20)
+}
+
+func TestContributionToDBPolicyRejected(t *testing.T) {
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		contributionMut(dossierID+"-1", 1000),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "POLICY_NOT_DC" {
+		t.Fatalf("expected POLICY_NOT_DC, got %+v", msgs)
+	}
+}
+
+func TestDefinedContributionRequiresAnnuityFactor(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-DC": `{"scheme_id":"SCHEME-DC","scheme_type":"DC","accrual_rate":0}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-DC", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "ANNUITY_FACTOR_MISSING" {
+		t.Fatalf("expected ANNUITY_FACTOR_MISSING, got %+v", msgs)
+	}
+}
+
+// --- franchise / max pensionable salary ---
+
+func TestRetirementFranchiseAndSalaryCap(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"franchise":15000,"max_pensionable_salary":70000}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 80000, 0.5),
+		addPolicyMut("SCHEME-A", "2005-01-01", 40000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "SALARY_CAPPED" || msgs[0].Level != model.LevelWarning {
+		t.Fatalf("expected one SALARY_CAPPED warning, got %+v", msgs)
+	}
+
+	y1 := serviceYears("2000-01-01", "2025-06-15")
+	y2 := serviceYears("2005-01-01", "2025-06-15")
+	// (min(salary, cap) - franchise) * part_time_factor
+	annual := ((70000-15000)*0.5*y1 + (40000-15000)*1.0*y2) * 0.02
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	assertFloat(t, "policy 1", *policies[0].AttainablePension, annual*y1/(y1+y2))
+	assertFloat(t, "policy 2", *policies[1].AttainablePension, annual*y2/(y1+y2))
+}
+
+func TestFranchiseFloorsAtZero(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"franchise":15000}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 10000, 1.0),
+		projectionMut("2021-01-01", "2022-01-01", 12),
+	))
+
+	if msgs := resp.CalculationResult.Messages; len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %+v", msgs)
+	}
+	projection := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].Projections[0]
+	assertFloat(t, "projected_pension", projection.ProjectedPension, 0)
+}
+
+// --- vesting / eligibility ---
+
+func TestUnvestedPolicyGetsNoPension(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02}`,
+		"SCHEME-B": `{"scheme_id":"SCHEME-B","accrual_rate":0.02,"vesting_period_years":5}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2022-01-01", 60000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "POLICY_NOT_VESTED" || msgs[0].Level != model.LevelWarning {
+		t.Fatalf("expected one POLICY_NOT_VESTED warning, got %+v", msgs)
+	}
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	// The vested policy's pension is unaffected by the unvested one
+	assertFloat(t, "vested", *policies[0].AttainablePension, 50000*serviceYears("2000-01-01", "2025-06-15")*0.02)
+	assertFloat(t, "unvested", *policies[1].AttainablePension, 0)
+}
+
+func TestSchemeMinimumServiceYears(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"min_service_years":20}`,
+	})
+
+	// 60 years old with 25 years of service
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "1995-06-15", 50000, 1.0),
+		retirementMut("2020-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+}
+
+func TestEligibilityMode(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.02,"min_retirement_age":60}`,
+		"SCHEME-B": `{"scheme_id":"SCHEME-B","accrual_rate":0.02}`,
+	})
+	t.Cleanup(func() { mutations.SetEligibilityMode("") })
+
+	// 62 years old: eligible under SCHEME-A but not under SCHEME-B
+	req := func() *model.CalculationRequest {
+		return makeReq("test",
+			createDossierMut(),
+			addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+			addPolicyMut("SCHEME-B", "2005-01-01", 50000, 1.0),
+			retirementMut("2022-06-15"),
+		)
+	}
+
+	msgs := Process(req()).CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "NOT_ELIGIBLE" {
+		t.Fatalf("strictest: expected NOT_ELIGIBLE, got %+v", msgs)
+	}
+
+	mutations.SetEligibilityMode(mutations.EligibilityLenient)
+	if resp := Process(req()); resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("lenient: expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+}
+
+// --- scheme provider ---
+
+// countingProvider serves fixed schemes and counts how often each is asked for.
+type countingProvider struct {
+	schemes map[string]schemeregistry.Scheme
+	calls   map[string]int
+}
+
+func (p *countingProvider) GetSchemes(ids []string, r schemeregistry.DateRange) map[string]schemeregistry.Scheme {
+	result := make(map[string]schemeregistry.Scheme, len(ids))
+	for _, id := range ids {
+		p.calls[id]++
+		sc, ok := p.schemes[id]
+		if !ok {
+			sc = schemeregistry.DefaultScheme(id)
+		}
+		result[id] = sc.Between(r)
+	}
+	return result
+}
+
+func TestProcessWithProviderFetchesSchemeOnce(t *testing.T) {
+	schemeA := schemeregistry.DefaultScheme("SCHEME-A")
+	schemeA.AccrualRate = 0.03
+	provider := &countingProvider{
+		schemes: map[string]schemeregistry.Scheme{"SCHEME-A": schemeA},
+		calls:   map[string]int{},
+	}
+
+	resp := ProcessWith(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		indexationMut(0.03, "", ""),
+		projectionMut("2021-01-01", "2025-01-01", 12),
+		retirementMut("2025-06-15"),
+	), provider)
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, 51500*serviceYears("2000-01-01", "2025-06-15")*0.03)
+	if provider.calls["SCHEME-A"] != 1 {
+		t.Fatalf("expected SCHEME-A to be fetched once, got %d", provider.calls["SCHEME-A"])
+	}
+}
+
+func TestIndexationPolicyNone(t *testing.T) {
+	useSchemes(t, map[string]string{
+		"SCHEME-B": `{"scheme_id":"SCHEME-B","accrual_rate":0.02,"indexation_policy":"NONE"}`,
+	})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2000-01-01", 50000, 1.0),
+		indexationMut(0.03, "", ""),
+	))
+
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	assertFloat(t, "indexed", policies[0].Salary, 51500)
+	assertFloat(t, "not indexed", policies[1].Salary, 50000)
+}
+
+// --- accrual rate periods ---
+
+const datedScheme = `{"scheme_id":"SCHEME-A","accrual_rate":0.0175,"accrual_rate_periods":[{"valid_from":"2015-01-01","accrual_rate":0.01875}]}`
+
+func TestRetirementSplitsServiceAtRatePeriods(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-A": datedScheme})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		changeSalaryMut(dossierID+"-1", "2010-01-01", 60000),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+
+	want := 50000*serviceYears("2000-01-01", "2010-01-01")*0.0175 +
+		60000*serviceYears("2010-01-01", "2015-01-01")*0.0175 +
+		60000*serviceYears("2015-01-01", "2025-06-15")*0.01875
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, want)
+}
+
+func TestProjectionUsesRateInEffect(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-A": datedScheme})
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2010-01-01", 50000, 1.0),
+		projectionMut("2014-01-01", "2016-01-01", 24),
+	))
+
+	projections := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].Projections
+	assertFloat(t, "before change", projections[0].ProjectedPension, 50000*serviceYears("2010-01-01", "2014-01-01")*0.0175)
+	assertFloat(t, "after change", projections[1].ProjectedPension,
+		50000*serviceYears("2010-01-01", "2015-01-01")*0.0175+50000*serviceYears("2015-01-01", "2016-01-01")*0.01875)
+}
+
+// openSchemeDir writes files into a temporary directory and opens it as a
+// scheme registry.
+func openSchemeDir(t *testing.T, files map[string]string) (*schemeregistry.FileProvider, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := schemeregistry.OpenDir(dir)
+	if err != nil {
+		t.Fatalf("OpenDir: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, dir
+}
+
+const datedSchemeYAML = `scheme_id: SCHEME-A
+accrual_rate: 0.0175
+accrual_rate_periods:
+  - valid_from: 2015-01-01
+    accrual_rate: 0.01875
+`
+
+func TestFileProviderVersionInMetadata(t *testing.T) {
+	provider, _ := openSchemeDir(t, map[string]string{"scheme-a.yaml": datedSchemeYAML})
+
+	resp := ProcessWith(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2010-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	), provider)
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+	if v := resp.CalculationMetadata.SchemeRegistryVersion; v == "" || v != provider.Version() {
+		t.Fatalf("expected scheme_registry_version %q, got %q", provider.Version(), v)
+	}
+
+	want := 50000*serviceYears("2010-01-01", "2015-01-01")*0.0175 + 50000*serviceYears("2015-01-01", "2025-06-15")*0.01875
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	assertFloat(t, "attainable_pension", *policy.AttainablePension, want)
+
+	if v := Process(makeReq("test", createDossierMut())).CalculationMetadata.SchemeRegistryVersion; v != "" {
+		t.Fatalf("expected no scheme_registry_version from the HTTP registry, got %q", v)
+	}
+}
+
+func TestRegistryFailureWarnsAndIsRetried(t *testing.T) {
+	var up atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"scheme_id":"SCHEME-A","accrual_rate":0.03}`))
+	}))
+	schemeregistry.SetURL(srv.URL)
+	t.Cleanup(func() {
+		schemeregistry.SetURL("")
+		srv.Close()
+	})
+	req := func() *model.CalculationRequest {
+		return makeReq("test",
+			createDossierMut(),
+			addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+			retirementMut("2025-06-15"),
+		)
+	}
+
+	resp := Process(req())
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %+v", resp.CalculationResult.Messages)
+	}
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "SCHEME_REGISTRY_UNAVAILABLE" || msgs[0].Level != model.LevelWarning ||
+		!strings.Contains(msgs[0].Message, "SCHEME-A") {
+		t.Fatalf("expected one SCHEME_REGISTRY_UNAVAILABLE warning naming SCHEME-A, got %+v", msgs)
+	}
+	if idx := resp.CalculationResult.Mutations[2].CalculationMessageIndexes; len(idx) != 1 || idx[0] != 0 {
+		t.Fatalf("expected the warning on the retirement mutation, got %v", idx)
+	}
+	pension := *resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].AttainablePension
+	assertFloat(t, "default rate", pension, 50000*serviceYears("2000-01-01", "2025-06-15")*0.02)
+
+	// The fallback was not cached: the next calculation fetches the scheme
+	up.Store(true)
+	resp = Process(req())
+	if msgs := resp.CalculationResult.Messages; len(msgs) != 0 {
+		t.Fatalf("expected no messages once the registry is up, got %+v", msgs)
+	}
+	pension = *resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0].AttainablePension
+	assertFloat(t, "registry rate", pension, 50000*serviceYears("2000-01-01", "2025-06-15")*0.03)
+}
+
+func TestRegistryFailureStrictMode(t *testing.T) {
+	useSchemes(t, nil)
+	schemeregistry.SetFailureMode(schemeregistry.FailureStrict)
+	t.Cleanup(func() { schemeregistry.SetFailureMode("") })
+
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	))
+	if resp.CalculationMetadata.CalculationOutcome != "FAILURE" {
+		t.Fatalf("expected FAILURE, got %+v", resp.CalculationResult.Messages)
 	}
-	if resp.CalculationResult.Messages[0].Code != "DOSSIER_NOT_FOUND" {
-		t.Fatalf("expected DOSSIER_NOT_FOUND")
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "SCHEME_REGISTRY_UNAVAILABLE" || msgs[0].Level != model.LevelCritical {
+		t.Fatalf("expected one critical SCHEME_REGISTRY_UNAVAILABLE, got %+v", msgs)
+	}
+	retirement := resp.CalculationResult.Mutations[2]
+	if string(retirement.ForwardPatch) != "[]" || string(retirement.BackwardPatch) != "[]" {
+		t.Fatalf("expected empty patches, got %s / %s", retirement.ForwardPatch, retirement.BackwardPatch)
+	}
+	d := resp.CalculationResult.EndSituation.Situation.Dossier
+	if d.Status != "ACTIVE" || d.Policies[0].AttainablePension != nil {
+		t.Fatalf("expected the retirement to be undone, got %+v", d)
 	}
 }
 
-// --- Full flow (README example) ---
+func TestSchemeOutsideValidityIsUnavailable(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-A": `{"scheme_id":"SCHEME-A","accrual_rate":0.03,"valid_to":"2024-12-31"}`})
 
-func TestFullFlowReadmeExample(t *testing.T) {
-	resp := Process(makeReq("tenant-001",
+	resp := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		projectionMut("2019-01-01", "2020-01-01", 12),
+		retirementMut("2025-06-15"),
+	))
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "SCHEME_REGISTRY_UNAVAILABLE" || !strings.Contains(msgs[0].Message, "not valid on 2025-06-15") {
+		t.Fatalf("expected one SCHEME_REGISTRY_UNAVAILABLE for the retirement date, got %+v", msgs)
+	}
+	if idx := resp.CalculationResult.Mutations[3].CalculationMessageIndexes; len(idx) != 1 || idx[0] != 0 {
+		t.Fatalf("expected the warning on the retirement mutation, got %v", idx)
+	}
+	policy := resp.CalculationResult.EndSituation.Situation.Dossier.Policies[0]
+	assertFloat(t, "projection within validity", policy.Projections[1].ProjectedPension, 50000*serviceYears("2000-01-01", "2020-01-01")*0.03)
+	assertFloat(t, "retirement after valid_to", *policy.AttainablePension, 50000*serviceYears("2000-01-01", "2025-06-15")*0.02)
+}
+
+func TestFileProviderReportsUndefinedScheme(t *testing.T) {
+	provider, _ := openSchemeDir(t, map[string]string{"scheme-a.yaml": datedSchemeYAML})
+
+	resp := ProcessWith(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-B", "2000-01-01", 50000, 1.0),
+		retirementMut("2025-06-15"),
+	), provider)
+	msgs := resp.CalculationResult.Messages
+	if len(msgs) != 1 || msgs[0].Code != "SCHEME_REGISTRY_UNAVAILABLE" || !strings.Contains(msgs[0].Message, "SCHEME-B") {
+		t.Fatalf("expected SCHEME_REGISTRY_UNAVAILABLE for SCHEME-B, got %+v", msgs)
+	}
+}
+
+// --- situation reconstruction ---
+
+func TestReconstructForwardAndBackwardAgree(t *testing.T) {
+	useSchemes(t, map[string]string{"SCHEME-DC": dcScheme})
+
+	resp := Process(makeReq("test",
 		createDossierMut(),
 		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8),
+		addPolicyMut("SCHEME-DC", "2012-01-01", 30000, 1.0),
+		contributionMut(dossierID+"-3", 50000),
+		investmentReturnMut(0.05, "SCHEME-DC"),
+		contributionMut(dossierID+"-3", 5000),
+		addPartnerMut("p4444444-4444-4444-4444-444444444444", "1962-03-01"),
+		projectionMut("2021-01-01", "2025-01-01", 12),
+		changeSalaryMut(dossierID+"-1", "2015-01-01", 55000),
+		terminateMut(dossierID+"-2", "2022-06-30"),
 		indexationMut(0.03, "", ""),
+		changePartTimeMut(dossierID+"-1", "2021-01-01", 0.8),
+		projectionMut("2022-01-01", "2024-01-01", 12),
+		divorceSplitMut("p4444444-4444-4444-4444-444444444444", "2005-01-01", "2020-01-01", 0.5),
+		addPartnerMut("p5555555-5555-5555-5555-555555555555", "1965-01-01"),
+		retirementMut("2025-06-15"),
+		commuteMut(0.2, 15),
+		registerDeathMut("2026-01-01"),
 	))
+	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
+		t.Fatalf("expected SUCCESS, got %s: %+v", resp.CalculationMetadata.CalculationOutcome, resp.CalculationResult.Messages)
+	}
+	result := &resp.CalculationResult
+
+	for i := -1; i < len(result.Mutations); i++ {
+		fwd, err := Reconstruct(result, i, DirectionForward)
+		if err != nil {
+			t.Fatalf("forward %d: %v", i, err)
+		}
+		bwd, err := Reconstruct(result, i, DirectionBackward)
+		if err != nil {
+			t.Fatalf("backward %d: %v", i, err)
+		}
+		f, _ := json.Marshal(fwd)
+		b, _ := json.Marshal(bwd)
+		if string(f) != string(b) {
+			t.Fatalf("index %d: forward and backward differ\nforward:  %s\nbackward: %s", i, f, b)
+		}
+	}
+
+	last, _ := Reconstruct(result, len(result.Mutations)-1, DirectionForward)
+	got, _ := json.Marshal(last.Situation)
+	want, _ := json.Marshal(result.EndSituation.Situation)
+	if string(got) != string(want) {
+		t.Fatalf("forward replay does not reach end situation\ngot:  %s\nwant: %s", got, want)
+	}
+
+	initial, _ := Reconstruct(result, -1, DirectionBackward)
+	if initial.Situation.Dossier != nil {
+		t.Fatal("expected backward replay to reach the empty initial situation")
+	}
+}
+
+func TestReconstructRejectsFailedMutation(t *testing.T) {
+	resp := Process(makeReq("test", createDossierMut(), retirementMut("2025-01-01")))
+
+	if _, err := Reconstruct(&resp.CalculationResult, 1, DirectionForward); err == nil {
+		t.Fatal("expected error for index of a failed mutation")
+	}
+}
+
+func TestReconstructRejectsEndIndexOutOfRange(t *testing.T) {
+	resp := Process(makeReq("test", createDossierMut()))
+
+	for _, last := range []int{5, -2} {
+		result := resp.CalculationResult
+		result.EndSituation.MutationIndex = last
+		for _, dir := range []string{DirectionForward, DirectionBackward} {
+			if _, err := Reconstruct(&result, 0, dir); !errors.Is(err, ErrMutationIndex) {
+				t.Fatalf("end index %d, %s: expected ErrMutationIndex, got %v", last, dir, err)
+			}
+		}
+	}
+}
+
+// --- initial_situation ---
+
+func TestInitialSituationSeedsPolicyNumbering(t *testing.T) {
+	seed := Process(makeReq("test", createDossierMut(), addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0)))
+	end := seed.CalculationResult.EndSituation
+
+	req := makeReq("test", addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8))
+	req.CalculationInstructions.InitialSituation = &model.InitialSituation{
+		ActualAt:  end.ActualAt,
+		Situation: end.Situation,
+		PolicySeq: 1,
+	}
+	resp := Process(req)
 
 	if resp.CalculationMetadata.CalculationOutcome != "SUCCESS" {
-		t.Fatalf("expected SUCCESS")
+		t.Fatalf("expected SUCCESS, got %s", resp.CalculationMetadata.CalculationOutcome)
 	}
-	if resp.CalculationMetadata.TenantID != "tenant-001" {
-		t.Fatalf("expected tenant-001")
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	if len(policies) != 2 || policies[1].PolicyID != dossierID+"-2" {
+		t.Fatalf("expected second policy %s-2, got %+v", dossierID, policies)
 	}
-	if len(resp.CalculationResult.Messages) != 0 {
-		t.Fatalf("expected 0 messages, got %d", len(resp.CalculationResult.Messages))
+	if got := resp.CalculationResult.InitialSituation.Situation.Dossier; got == nil || len(got.Policies) != 1 {
+		t.Fatal("expected initial situation to echo the supplied dossier")
 	}
-	if len(resp.CalculationResult.Mutations) != 3 {
-		t.Fatalf("expected 3 mutations, got %d", len(resp.CalculationResult.Mutations))
+	if len(end.Situation.Dossier.Policies) != 1 {
+		t.Fatal("supplied situation must not be mutated")
 	}
+}
 
-	end := resp.CalculationResult.EndSituation
-	if end.MutationIndex != 2 {
-		t.Fatalf("expected mutation_index 2, got %d", end.MutationIndex)
+func TestInitialSituationSeedsFromPolicyIDs(t *testing.T) {
+	seed := Process(makeReq("test", createDossierMut(), addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0)))
+
+	req := makeReq("test", addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8))
+	req.CalculationInstructions.InitialSituation = &model.InitialSituation{
+		ActualAt:  "2020-01-01",
+		Situation: seed.CalculationResult.EndSituation.Situation,
 	}
-	if end.ActualAt != "2021-01-01" {
-		t.Fatalf("expected actual_at 2021-01-01, got %s", end.ActualAt)
+	resp := Process(req)
+
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	if policies[1].PolicyID != dossierID+"-2" {
+		t.Fatalf("expected %s-2 without policy_seq, got %s", dossierID, policies[1].PolicyID)
 	}
+}
 
-	policies := end.Situation.Dossier.Policies
-	if len(policies) != 1 {
-		t.Fatalf("expected 1 policy, got %d", len(policies))
+func TestEndSituationRoundTripAfterRemovePolicy(t *testing.T) {
+	first := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		addPolicyMut("SCHEME-B", "2010-01-01", 60000, 0.8),
+		removePolicyMut(dossierID+"-2"),
+	))
+	end := first.CalculationResult.EndSituation
+	if end.PolicySeq != 2 {
+		t.Fatalf("expected end_situation policy_seq 2, got %d", end.PolicySeq)
 	}
-	assertFloat(t, "salary after indexation", policies[0].Salary, 51500)
-	if policies[0].PolicyID != dossierID+"-1" {
-		t.Fatalf("expected policy_id %s-1", dossierID)
+
+	// Through JSON, as a client feeding end_situation back would
+	var initial model.InitialSituation
+	b, _ := json.Marshal(end)
+	json.Unmarshal(b, &initial)
+	req := makeReq("test", addPolicyMut("SCHEME-C", "2015-01-01", 40000, 1.0))
+	req.CalculationInstructions.InitialSituation = &initial
+	resp := Process(req)
+
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	if len(policies) != 2 || policies[1].PolicyID != dossierID+"-3" {
+		t.Fatalf("expected the new policy to be %s-3, got %+v", dossierID, policies)
+	}
+	if got := resp.CalculationResult.EndSituation.PolicySeq; got != 3 {
+		t.Fatalf("expected policy_seq 3, got %d", got)
+	}
+}
+
+func TestInitialSituationInvalid(t *testing.T) {
+	req := makeReq("test", addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0))
+	req.CalculationInstructions.InitialSituation = &model.InitialSituation{
+		ActualAt:  "2020-01-01",
+		Situation: model.Situation{Dossier: &model.Dossier{DossierID: dossierID, Status: "UNKNOWN"}},
+	}
+	resp := Process(req)
+
+	if resp.CalculationMetadata.CalculationOutcome != "FAILURE" {
+		t.Fatalf("expected FAILURE, got %s", resp.CalculationMetadata.CalculationOutcome)
+	}
+	if resp.CalculationResult.Messages[0].Code != "INVALID_INITIAL_SITUATION" {
+		t.Fatalf("expected INVALID_INITIAL_SITUATION, got %s", resp.CalculationResult.Messages[0].Code)
+	}
+	if len(resp.CalculationResult.Mutations) != 0 {
+		t.Fatalf("expected no processed mutations, got %d", len(resp.CalculationResult.Mutations))
+	}
+	end := resp.CalculationResult.EndSituation
+	if end.MutationIndex != -1 || end.MutationID != "" || end.Situation.Dossier != nil {
+		t.Fatalf("expected an empty end situation at mutation_index -1, got %+v", end)
+	}
+}
+
+func TestInitialSituationRejectsInconsistentPolicies(t *testing.T) {
+	seed := Process(makeReq("test",
+		createDossierMut(),
+		addPolicyMut("SCHEME-A", "2000-01-01", 50000, 1.0),
+		changeSalaryMut(dossierID+"-1", "2010-01-01", 60000),
+	)).CalculationResult.EndSituation.Situation
+	amount, negative, early := 100.0, -1.0, "1999-12-31"
+
+	for name, corrupt := range map[string]func(d *model.Dossier){
+		"history starts late": func(d *model.Dossier) { d.Policies[0].SalaryHistory[0].StartDate = "2005-01-01" },
+		"history unordered": func(d *model.Dossier) {
+			h := d.Policies[0].SalaryHistory
+			h[0], h[1] = h[1], h[0]
+		},
+		"history salary negative":  func(d *model.Dossier) { d.Policies[0].SalaryHistory[1].Salary = -1 },
+		"end before start":         func(d *model.Dossier) { d.Policies[0].EmploymentEndDate = &early },
+		"capital_balance negative": func(d *model.Dossier) { d.Policies[0].CapitalBalance = &negative },
+		"entitlement unknown policy": func(d *model.Dossier) {
+			d.Persons[0].PensionEntitlements = []model.PensionEntitlement{{PolicyID: dossierID + "-9", Amount: amount}}
+		},
+	} {
+		situation := seed.Clone()
+		corrupt(situation.Dossier)
+		// A change dated before a late history must not reach the handler
+		req := makeReq("test", changeSalaryMut(dossierID+"-1", "2002-01-01", 55000))
+		req.CalculationInstructions.InitialSituation = &model.InitialSituation{ActualAt: "2020-01-01", Situation: situation}
+		resp := Process(req)
+
+		msgs := resp.CalculationResult.Messages
+		if len(msgs) == 0 || msgs[0].Code != "INVALID_INITIAL_SITUATION" || len(resp.CalculationResult.Mutations) != 0 {
+			t.Errorf("%s: expected INVALID_INITIAL_SITUATION, got %+v", name, msgs)
+		}
 	}
 }
 
@@ -401,6 +1688,22 @@ func indexationMut(pct float64, schemeID, effectiveBefore string) model.Mutation
 	}
 }
 
+func projectionMut(start, end string, interval int) model.Mutation {
+	props, _ := json.Marshal(map[string]any{
+		"projection_start_date":      start,
+		"projection_end_date":        end,
+		"projection_interval_months": interval,
+	})
+	return model.Mutation{
+		MutationID:             "e7777777-7777-7777-7777-777777777777",
+		MutationDefinitionName: "project_future_benefits",
+		MutationType:           "DOSSIER",
+		ActualAt:               start,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
 func indexationMutWithScheme(pct float64, schemeID string) model.Mutation {
 	return indexationMut(pct, schemeID, "")
 }
@@ -420,3 +1723,160 @@ func retirementMut(date string) model.Mutation {
 		MutationProperties:     json.RawMessage(props),
 	}
 }
+
+func terminateMut(policyID, endDate string) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"policy_id": policyID, "employment_end_date": endDate})
+	return model.Mutation{
+		MutationID:             "f" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "terminate_employment",
+		MutationType:           "DOSSIER",
+		ActualAt:               endDate,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func changeSalaryMut(policyID, date string, salary float64) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"policy_id": policyID, "effective_date": date, "salary": salary})
+	return model.Mutation{
+		MutationID:             "g" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "change_salary",
+		MutationType:           "DOSSIER",
+		ActualAt:               date,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func changePartTimeMut(policyID, date string, factor float64) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"policy_id": policyID, "effective_date": date, "part_time_factor": factor})
+	return model.Mutation{
+		MutationID:             "h" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "change_part_time_factor",
+		MutationType:           "DOSSIER",
+		ActualAt:               date,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func removePolicyMut(policyID string) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"policy_id": policyID})
+	return model.Mutation{
+		MutationID:             "i" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "remove_policy",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2021-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func addPartnerMut(personID, birthDate string) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"person_id": personID, "name": "John Doe", "birth_date": birthDate})
+	return model.Mutation{
+		MutationID:             "j" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "add_partner",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2020-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func removePartnerMut(personID string) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"person_id": personID})
+	return model.Mutation{
+		MutationID:             "k" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "remove_partner",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2020-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func registerDeathMut(date string) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"date_of_death": date})
+	return model.Mutation{
+		MutationID:             "l" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "register_death",
+		MutationType:           "DOSSIER",
+		ActualAt:               date,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func divorceSplitMut(personID, marriageStart, marriageEnd string, split float64) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{
+		"person_id":           personID,
+		"marriage_start_date": marriageStart,
+		"marriage_end_date":   marriageEnd,
+		"split_percentage":    split,
+	})
+	return model.Mutation{
+		MutationID:             "m" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "divorce_split",
+		MutationType:           "DOSSIER",
+		ActualAt:               marriageEnd,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+// commuteMut omits annuity_factor when factor is 0.
+func commuteMut(pct, factor float64) model.Mutation {
+	mutSeq++
+	p := map[string]any{"commutation_percentage": pct}
+	if factor != 0 {
+		p["annuity_factor"] = factor
+	}
+	props, _ := json.Marshal(p)
+	return model.Mutation{
+		MutationID:             "n" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "commute_pension",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2025-07-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func contributionMut(policyID string, amount float64) model.Mutation {
+	mutSeq++
+	props, _ := json.Marshal(map[string]any{"policy_id": policyID, "amount": amount})
+	return model.Mutation{
+		MutationID:             "o" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "add_contribution",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2021-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
+
+func investmentReturnMut(pct float64, schemeID string) model.Mutation {
+	mutSeq++
+	p := map[string]any{"percentage": pct}
+	if schemeID != "" {
+		p["scheme_id"] = schemeID
+	}
+	props, _ := json.Marshal(p)
+	return model.Mutation{
+		MutationID:             "q" + string(rune('0'+mutSeq)) + "000000-0000-0000-0000-000000000000",
+		MutationDefinitionName: "apply_investment_return",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2021-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"pension-engine/internal/model"
+)
+
+// validateInitialSituation checks a caller-supplied starting situation.
+// It returns one CRITICAL message per problem found.
+func validateInitialSituation(init *model.InitialSituation) []model.CalculationMessage {
+	var problems []string
+
+	if !validDate(init.ActualAt) {
+		problems = append(problems, "actual_at must be a date in YYYY-MM-DD format")
+	}
+	if init.PolicySeq < 0 {
+		problems = append(problems, "policy_seq must be non-negative")
+	}
+
+	if d := init.Situation.Dossier; d != nil {
+		if strings.TrimSpace(d.DossierID) == "" {
+			problems = append(problems, "dossier_id is required")
+		}
+		if !knownStatus(d.Status) {
+			problems = append(problems, "status "+strconv.Quote(d.Status)+" is not a valid dossier status")
+		}
+		if d.RetirementDate != nil && !validDate(*d.RetirementDate) {
+			problems = append(problems, "retirement_date must be a date in YYYY-MM-DD format")
+		}
+
+		participants := 0
+		for _, p := range d.Persons {
+			if p.Role == "PARTICIPANT" {
+				participants++
+				if !validDate(p.BirthDate) {
+					problems = append(problems, "birth_date of person "+p.PersonID+" is invalid")
+				}
+			}
+		}
+		if participants != 1 {
+			problems = append(problems, "dossier must have exactly one PARTICIPANT")
+		}
+
+		seen := make(map[string]struct{}, len(d.Policies))
+		for i, p := range d.Policies {
+			where := "policy " + strconv.Itoa(i)
+			if p.PolicyID == "" {
+				problems = append(problems, where+": policy_id is required")
+			} else if _, dup := seen[p.PolicyID]; dup {
+				problems = append(problems, where+": duplicate policy_id "+p.PolicyID)
+			}
+			seen[p.PolicyID] = struct{}{}
+			if !validDate(p.EmploymentStartDate) {
+				problems = append(problems, where+": employment_start_date is invalid")
+			}
+			if p.Salary < 0 {
+				problems = append(problems, where+": salary must be non-negative")
+			}
+			if p.PartTimeFactor < 0 || p.PartTimeFactor > 1 {
+				problems = append(problems, where+": part_time_factor must be between 0 and 1")
+			}
+			if p.EmploymentEndDate != nil && (!validDate(*p.EmploymentEndDate) || *p.EmploymentEndDate < p.EmploymentStartDate) {
+				problems = append(problems, where+": employment_end_date must be a date not before employment_start_date")
+			}
+			if p.CapitalBalance != nil && *p.CapitalBalance < 0 {
+				problems = append(problems, where+": capital_balance must be non-negative")
+			}
+			// The handlers rely on a history ordered by date that starts with
+			// the employment
+			for j, seg := range p.SalaryHistory {
+				at := where + ": salary_history " + strconv.Itoa(j)
+				switch {
+				case !validDate(seg.StartDate):
+					problems = append(problems, at+": start_date is invalid")
+				case j == 0 && seg.StartDate != p.EmploymentStartDate:
+					problems = append(problems, at+": start_date must be the employment_start_date")
+				case j > 0 && seg.StartDate <= p.SalaryHistory[j-1].StartDate:
+					problems = append(problems, at+": start_date must be after the previous segment's")
+				}
+				if seg.Salary < 0 {
+					problems = append(problems, at+": salary must be non-negative")
+				}
+				if seg.PartTimeFactor < 0 || seg.PartTimeFactor > 1 {
+					problems = append(problems, at+": part_time_factor must be between 0 and 1")
+				}
+			}
+		}
+
+		for _, person := range d.Persons {
+			for j, e := range person.PensionEntitlements {
+				where := "person " + person.PersonID + ": pension_entitlements " + strconv.Itoa(j)
+				if _, ok := seen[e.PolicyID]; !ok {
+					problems = append(problems, where+": policy_id "+strconv.Quote(e.PolicyID)+" does not exist")
+				}
+				if e.Amount < 0 {
+					problems = append(problems, where+": amount must be non-negative")
+				}
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	msgs := make([]model.CalculationMessage, len(problems))
+	for i, p := range problems {
+		msgs[i] = model.CalculationMessage{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_INITIAL_SITUATION",
+			Message: "Initial situation: " + p,
+		}
+	}
+	return msgs
+}
+
+// seedPolicySeq restores the hidden policy counter on a supplied dossier.
+// The supplied policy_seq wins unless an existing policy ID already uses a
+// higher number, so generated IDs never collide with existing ones.
+func seedPolicySeq(d *model.Dossier, supplied int) {
+	seq := supplied
+	prefix := d.DossierID + "-"
+	for _, p := range d.Policies {
+		if !strings.HasPrefix(p.PolicyID, prefix) {
+			continue
+		}
+		if n, err := strconv.Atoi(p.PolicyID[len(prefix):]); err == nil && n > seq {
+			seq = n
+		}
+	}
+	d.PolicySeq = seq
+}
+
+func validDate(s string) bool {
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
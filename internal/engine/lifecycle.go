@@ -0,0 +1,58 @@
+package engine
+
+import "pension-engine/internal/model"
+
+// Dossier lifecycle:
+//
+//	ACTIVE ──calculate_retirement_benefit──▶ RETIRED
+//	ACTIVE ──register_death──▶ DECEASED
+//	RETIRED ──register_death──▶ DECEASED
+//
+// allowedStatuses lists the dossier statuses each mutation may run in. The
+// engine rejects a mutation on any other status with CRITICAL
+// DOSSIER_<STATUS> before its handler runs, so handlers never see a dossier
+// in a state they do not support. Mutations that are not listed, such as
+// create_dossier, are not bound to a status.
+var allowedStatuses = map[string][]string{
+	"add_policy":                   {model.StatusActive, model.StatusRetired},
+	"apply_indexation":             {model.StatusActive, model.StatusRetired},
+	"calculate_retirement_benefit": {model.StatusActive, model.StatusRetired},
+	"project_future_benefits":      {model.StatusActive, model.StatusRetired},
+	"terminate_employment":         {model.StatusActive, model.StatusRetired},
+	"change_salary":                {model.StatusActive, model.StatusRetired},
+	"change_part_time_factor":      {model.StatusActive, model.StatusRetired},
+	"remove_policy":                {model.StatusActive},
+	"add_partner":                  {model.StatusActive},
+	"remove_partner":               {model.StatusActive},
+	"register_death":               {model.StatusActive, model.StatusRetired},
+	"divorce_split":                {model.StatusActive},
+	"commute_pension":              {model.StatusRetired},
+	"add_contribution":             {model.StatusActive},
+	"apply_investment_return":      {model.StatusActive},
+}
+
+// knownStatus reports whether status is part of the lifecycle.
+func knownStatus(status string) bool {
+	return status == model.StatusActive || status == model.StatusRetired || status == model.StatusDeceased
+}
+
+// checkLifecycle returns a CRITICAL message when the mutation is not allowed
+// in the dossier's current status. Without a dossier the handler reports
+// DOSSIER_NOT_FOUND itself.
+func checkLifecycle(state *model.Situation, name string) []model.CalculationMessage {
+	allowed, bound := allowedStatuses[name]
+	if !bound || state.Dossier == nil {
+		return nil
+	}
+	status := state.Dossier.Status
+	for _, s := range allowed {
+		if s == status {
+			return nil
+		}
+	}
+	return []model.CalculationMessage{{
+		Level:   model.LevelCritical,
+		Code:    "DOSSIER_" + status,
+		Message: name + " is not allowed on a " + status + " dossier",
+	}}
+}
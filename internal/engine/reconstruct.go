@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/jsonpatch"
+	"pension-engine/internal/model"
+)
+
+const (
+	DirectionForward  = "forward"
+	DirectionBackward = "backward"
+)
+
+// ErrMutationIndex is returned when a reconstruction targets a mutation
+// whose result is not part of the calculation's end situation.
+var ErrMutationIndex = errors.New("mutation_index out of range")
+
+// Reconstruct rebuilds the situation right after the mutation at index from
+// the patches recorded in result. Forward walks the forward patches from the
+// initial situation; backward walks the backward patches from the end
+// situation. An index of -1 yields the initial situation.
+func Reconstruct(result *model.CalculationResult, index int, direction string) (model.SituationEnvelope, error) {
+	last := result.EndSituation.MutationIndex
+	// The result comes from the client, so its end index is checked too
+	if last < -1 || last >= len(result.Mutations) {
+		return model.SituationEnvelope{}, &reconstructError{index: last, last: len(result.Mutations) - 1, end: true}
+	}
+	if index < -1 || index > last {
+		return model.SituationEnvelope{}, &reconstructError{index: index, last: last}
+	}
+
+	var (
+		doc *jsonpatch.Document
+		err error
+	)
+	switch direction {
+	case DirectionForward, "":
+		doc, err = situationDocument(&result.InitialSituation.Situation)
+		for i := 0; err == nil && i <= index; i++ {
+			err = applyPatch(doc, result.Mutations[i].ForwardPatch, i)
+		}
+	case DirectionBackward:
+		doc, err = situationDocument(&result.EndSituation.Situation)
+		for i := last; err == nil && i > index; i-- {
+			err = applyPatch(doc, result.Mutations[i].BackwardPatch, i)
+		}
+	default:
+		return model.SituationEnvelope{}, errors.New("direction must be forward or backward")
+	}
+	if err != nil {
+		return model.SituationEnvelope{}, err
+	}
+
+	env := model.SituationEnvelope{MutationIndex: index, ActualAt: result.InitialSituation.ActualAt}
+	if index >= 0 {
+		m := result.Mutations[index].Mutation
+		env.MutationID, env.ActualAt = m.MutationID, m.ActualAt
+	}
+	if err := doc.Decode(&env.Situation); err != nil {
+		return model.SituationEnvelope{}, err
+	}
+	return env, nil
+}
+
+func situationDocument(s *model.Situation) (*jsonpatch.Document, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.Parse(b)
+}
+
+func applyPatch(doc *jsonpatch.Document, patch []byte, index int) error {
+	if len(patch) == 0 {
+		return nil
+	}
+	if err := doc.Apply(patch); err != nil {
+		return errors.New("mutation " + strconv.Itoa(index) + ": " + err.Error())
+	}
+	return nil
+}
+
+type reconstructError struct {
+	index, last int
+	// end is set when the result's own end_situation index is out of range
+	end bool
+}
+
+func (e *reconstructError) Error() string {
+	prefix := ErrMutationIndex.Error()
+	if e.end {
+		prefix = "end_situation." + prefix
+	}
+	return prefix + ": " + strconv.Itoa(e.index) + " (expected -1.." + strconv.Itoa(e.last) + ")"
+}
+
+func (e *reconstructError) Unwrap() error {
+	return ErrMutationIndex
+}
+
+// Situations replays the forward patches of result once and returns the
+// situation after every mutation that was applied, indexed by mutation. A
+// mutation rejected with a CRITICAL message is always the last one
+// processed and is left out.
+func Situations(result *model.CalculationResult) ([]model.Situation, error) {
+	n := len(result.Mutations)
+	if n > 0 && hasCritical(result, &result.Mutations[n-1]) {
+		n--
+	}
+	doc, err := situationDocument(&result.InitialSituation.Situation)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Situation, n)
+	for i := 0; i < n; i++ {
+		if err := applyPatch(doc, result.Mutations[i].ForwardPatch, i); err != nil {
+			return nil, err
+		}
+		if err := doc.Decode(&out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func hasCritical(result *model.CalculationResult, pm *model.ProcessedMutation) bool {
+	for _, idx := range pm.CalculationMessageIndexes {
+		if idx < len(result.Messages) && result.Messages[idx].Level == model.LevelCritical {
+			return true
+		}
+	}
+	return false
+}
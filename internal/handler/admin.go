@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/schemeregistry"
+)
+
+const (
+	schemeCachePath = "/admin/scheme-cache"
+	metricsPath     = "/metrics"
+)
+
+type schemeCacheEntry struct {
+	SchemeID  string `json:"scheme_id"`
+	FetchedAt string `json:"fetched_at"`
+	Stale     bool   `json:"stale"`
+}
+
+type schemeCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Refreshes uint64 `json:"refreshes"`
+	Errors    uint64 `json:"errors"`
+}
+
+type schemeCacheResponse struct {
+	Entries []schemeCacheEntry `json:"entries"`
+	Stats   schemeCacheStats   `json:"stats"`
+}
+
+type purgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// authorizeAdmin reports whether the request may use an admin endpoint,
+// writing the response when it may not. The endpoints do not exist without a
+// token, and otherwise need it as a bearer token.
+func authorizeAdmin(ctx *fasthttp.RequestCtx, token string) bool {
+	if token == "" {
+		ctx.SetStatusCode(404)
+		return false
+	}
+	auth := ctx.Request.Header.Peek("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(string(auth[:len(prefix)]), prefix) ||
+		subtle.ConstantTimeCompare(auth[len(prefix):], []byte(token)) != 1 {
+		ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+		writeError(ctx, 401, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// handleSchemeCache serves the scheme registry cache endpoints:
+//
+//	GET    /admin/scheme-cache              list the cached schemes and the cache counters
+//	DELETE /admin/scheme-cache              purge every cached scheme
+//	DELETE /admin/scheme-cache/{scheme_id}  purge one cached scheme
+func handleSchemeCache(ctx *fasthttp.RequestCtx, path string) {
+	schemeID := strings.TrimPrefix(strings.TrimPrefix(path, schemeCachePath), "/")
+
+	switch {
+	case ctx.IsGet() && schemeID == "":
+		entries := schemeregistry.CacheEntries()
+		resp := schemeCacheResponse{
+			Entries: make([]schemeCacheEntry, len(entries)),
+			Stats:   schemeCacheStats(schemeregistry.Stats()),
+		}
+		for i, e := range entries {
+			resp.Entries[i] = schemeCacheEntry{
+				SchemeID:  e.SchemeID,
+				FetchedAt: e.FetchedAt.UTC().Format(time.RFC3339),
+				Stale:     e.Stale,
+			}
+		}
+		writeJSON(ctx, resp)
+	case ctx.IsDelete() && schemeID == "":
+		writeJSON(ctx, purgeResponse{Purged: schemeregistry.PurgeCache()})
+	case ctx.IsDelete():
+		writeJSON(ctx, purgeResponse{Purged: schemeregistry.PurgeCache(schemeID)})
+	default:
+		writeError(ctx, 405, "Method not allowed")
+	}
+}
+
+// handleMetrics writes the scheme registry cache counters in the Prometheus
+// text format.
+func handleMetrics(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeError(ctx, 405, "Method not allowed")
+		return
+	}
+	s := schemeregistry.Stats()
+	ctx.SetContentType("text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, help string
+		value      uint64
+	}{
+		{"scheme_cache_hits_total", "Scheme lookups served from the cache, fresh or stale.", s.Hits},
+		{"scheme_cache_misses_total", "Scheme lookups that waited for the registry.", s.Misses},
+		{"scheme_cache_refreshes_total", "Background refreshes of stale schemes.", s.Refreshes},
+		{"scheme_cache_errors_total", "Failed scheme registry fetches.", s.Errors},
+	} {
+		fmt.Fprintf(ctx, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", m.name, m.help, m.name, m.name, m.value)
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/schemeregistry"
+)
+
+func TestSchemeCacheEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/schemes/")
+		w.Write([]byte(`{"scheme_id":"` + id + `","accrual_rate":0.02}`))
+	}))
+	schemeregistry.SetURL(srv.URL)
+	t.Cleanup(func() {
+		schemeregistry.SetURL("")
+		srv.Close()
+	})
+	schemeregistry.Default().GetSchemes([]string{"SCHEME-A", "SCHEME-B"}, schemeregistry.DateRange{})
+
+	router := NewRouter(nil, testAdminToken)
+	var list schemeCacheResponse
+	json.Unmarshal(serve(router, "GET", "/admin/scheme-cache"), &list)
+	if len(list.Entries) != 2 || list.Entries[0].SchemeID != "SCHEME-A" || list.Entries[0].Stale {
+		t.Fatalf("expected SCHEME-A and SCHEME-B cached, got %+v", list.Entries)
+	}
+	if list.Stats.Misses < 2 {
+		t.Fatalf("expected at least 2 misses, got %+v", list.Stats)
+	}
+
+	var purged purgeResponse
+	json.Unmarshal(serve(router, "DELETE", "/admin/scheme-cache/SCHEME-A"), &purged)
+	if purged.Purged != 1 || len(schemeregistry.CacheEntries()) != 1 {
+		t.Fatalf("expected SCHEME-A purged, got %+v / %+v", purged, schemeregistry.CacheEntries())
+	}
+	json.Unmarshal(serve(router, "DELETE", "/admin/scheme-cache"), &purged)
+	if purged.Purged != 1 || len(schemeregistry.CacheEntries()) != 0 {
+		t.Fatalf("expected the cache emptied, got %+v / %+v", purged, schemeregistry.CacheEntries())
+	}
+
+	metrics := string(serve(router, "GET", "/metrics"))
+	for _, name := range []string{"scheme_cache_hits_total", "scheme_cache_misses_total", "scheme_cache_refreshes_total", "scheme_cache_errors_total"} {
+		if !strings.Contains(metrics, "\n"+name+" ") {
+			t.Fatalf("expected %s in metrics, got:\n%s", name, metrics)
+		}
+	}
+}
+
+func TestAdminEndpointsNeedToken(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		token  string
+		auth   string
+		status int
+	}{
+		{"disabled without a token", "", "Bearer " + testAdminToken, 404},
+		{"no credentials", testAdminToken, "", 401},
+		{"wrong token", testAdminToken, "Bearer wrong", 401},
+		{"not a bearer token", testAdminToken, testAdminToken, 401},
+		{"bearer token", testAdminToken, "Bearer " + testAdminToken, 200},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter(nil, tc.token)
+			for _, uri := range []string{"/admin/scheme-cache", "/metrics"} {
+				var ctx fasthttp.RequestCtx
+				ctx.Request.Header.SetMethod("GET")
+				ctx.Request.SetRequestURI(uri)
+				if tc.auth != "" {
+					ctx.Request.Header.Set("Authorization", tc.auth)
+				}
+				router(&ctx)
+				if got := ctx.Response.StatusCode(); got != tc.status {
+					t.Fatalf("GET %s: expected %d, got %d", uri, tc.status, got)
+				}
+			}
+		})
+	}
+}
+
+const testAdminToken = "s3cret"
+
+func serve(router fasthttp.RequestHandler, method, uri string) []byte {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router(&ctx)
+	return ctx.Response.Body()
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+)
+
+// batchError is written in place of a CalculationResponse for an item that
+// could not be calculated. Index is the item's 0-based input position.
+type batchError struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// HandleBatch calculates many CalculationRequests in one call. The body is
+// either a JSON array or NDJSON (one request per line). Results are
+// streamed back as NDJSON in input order.
+func HandleBatch(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeError(ctx, 405, "Method not allowed")
+		return
+	}
+
+	// The request body is recycled once the handler returns, but the
+	// response is streamed after that, so keep a private copy.
+	body := append([]byte(nil), ctx.PostBody()...)
+	items, err := splitBatch(body)
+	if err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+
+	results := make([]chan []byte, len(items))
+	for i := range results {
+		results[i] = make(chan []byte, 1)
+	}
+
+	jobs := make(chan int)
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(items) {
+		workers = len(items)
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- calculateItem(i, items[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range items {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	ctx.SetContentType("application/x-ndjson")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		for _, ch := range results {
+			w.Write(<-ch)
+			w.WriteByte('\n')
+			w.Flush()
+		}
+	})
+}
+
+// rawItem is one raw item of a batch, or the error that stopped the
+// batch from being split further.
+type rawItem struct {
+	raw json.RawMessage
+	err error
+}
+
+// splitBatch returns the raw items of a JSON array or NDJSON body. Array
+// elements are decoded one at a time, so a malformed element only fails
+// itself; when the array cannot be read past an element, that element
+// carries the error and ends the batch.
+func splitBatch(body []byte) ([]rawItem, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		var items []rawItem
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				items = append(items, rawItem{err: err})
+				break
+			}
+			items = append(items, rawItem{raw: raw})
+		}
+		return items, nil
+	}
+
+	var items []rawItem
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, rawItem{raw: line})
+		}
+	}
+	return items, nil
+}
+
+func calculateItem(index int, item rawItem) []byte {
+	if item.err != nil {
+		return marshalBatchError(index, 400, "Invalid request body: "+item.err.Error())
+	}
+	var req model.CalculationRequest
+	if err := json.Unmarshal(item.raw, &req); err != nil {
+		return marshalBatchError(index, 400, "Invalid request body: "+err.Error())
+	}
+	if len(req.CalculationInstructions.Mutations) == 0 {
+		return marshalBatchError(index, 400, "At least one mutation is required")
+	}
+	b, _ := json.Marshal(engine.Process(&req))
+	return b
+}
+
+func marshalBatchError(index, status int, message string) []byte {
+	b, _ := json.Marshal(batchError{Index: index, Status: status, Message: message})
+	return b
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+)
+
+const batchItem = `{"tenant_id":"t%d","calculation_instructions":{"mutations":[{"mutation_id":"m1","mutation_definition_name":"create_dossier","mutation_type":"DOSSIER_CREATION","actual_at":"2020-01-01","mutation_properties":{"dossier_id":"d1","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}}]}}`
+
+func TestHandleBatchKeepsOrderAndIsolatesBadItems(t *testing.T) {
+	for _, tc := range []struct{ name, body string }{
+		{"array", "[" + item(0) + `,{"tenant_id":1},` + item(2) + "]"},
+		{"ndjson", item(0) + "\n{not json\n\n" + item(2) + "\n"},
+		{"malformed array element", "[" + item(0) + `,{"tenant_id": ,"x"},` + item(2) + "]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := runBatch(t, tc.body)
+			if len(lines) != 3 {
+				t.Fatalf("expected 3 result lines, got %d", len(lines))
+			}
+
+			var first, third struct {
+				CalculationMetadata struct {
+					TenantID string `json:"tenant_id"`
+					Outcome  string `json:"calculation_outcome"`
+				} `json:"calculation_metadata"`
+			}
+			json.Unmarshal(lines[0], &first)
+			json.Unmarshal(lines[2], &third)
+			if first.CalculationMetadata.TenantID != "t0" || third.CalculationMetadata.TenantID != "t2" {
+				t.Fatalf("results out of order: %s / %s", first.CalculationMetadata.TenantID, third.CalculationMetadata.TenantID)
+			}
+			if first.CalculationMetadata.Outcome != "SUCCESS" {
+				t.Fatalf("expected SUCCESS, got %s", first.CalculationMetadata.Outcome)
+			}
+
+			var bad batchError
+			if err := json.Unmarshal(lines[1], &bad); err != nil || bad.Index != 1 || bad.Status != 400 {
+				t.Fatalf("expected error line for item 1, got %s", lines[1])
+			}
+		})
+	}
+}
+
+func item(i int) string {
+	return fmt.Sprintf(batchItem, i)
+}
+
+func runBatch(t *testing.T, body string) [][]byte {
+	t.Helper()
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/calculation-requests/batch")
+	ctx.Request.SetBodyString(body)
+
+	HandleBatch(&ctx)
+
+	if ctx.Response.StatusCode() != 200 {
+		t.Fatalf("expected 200, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	return bytes.Split(bytes.TrimSpace(ctx.Response.Body()), []byte("\n"))
+}
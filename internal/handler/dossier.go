@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/store"
+)
+
+type appendMutationsRequest struct {
+	Mutations []model.Mutation `json:"mutations"`
+}
+
+type mutationHistoryResponse struct {
+	TenantID  string           `json:"tenant_id"`
+	DossierID string           `json:"dossier_id"`
+	Mutations []model.Mutation `json:"mutations"`
+}
+
+// handleDossier serves the stored-dossier endpoints:
+//
+//	POST /tenants/{tenant_id}/dossiers/{dossier_id}/mutations  append and calculate mutations
+//	GET  /tenants/{tenant_id}/dossiers/{dossier_id}/mutations  list the mutation history
+//	GET  /tenants/{tenant_id}/dossiers/{dossier_id}/situation  fetch the current end situation
+//	POST /tenants/{tenant_id}/dossiers/{dossier_id}/retroactive-changes  insert or cancel a back-dated mutation
+func handleDossier(ctx *fasthttp.RequestCtx, st *store.Store, path string) {
+	// "/tenants/{t}/dossiers/{d}/{resource}" splits into 6 parts with a leading "".
+	parts := strings.Split(path, "/")
+	if len(parts) != 6 || parts[3] != "dossiers" || parts[2] == "" || parts[4] == "" {
+		ctx.SetStatusCode(404)
+		return
+	}
+	tenantID, dossierID, resource := parts[2], parts[4], parts[5]
+
+	switch {
+	case resource == "mutations" && ctx.IsPost():
+		appendMutations(ctx, st, tenantID, dossierID)
+	case resource == "mutations" && ctx.IsGet():
+		d, ok := getDossier(ctx, st, tenantID, dossierID)
+		if !ok {
+			return
+		}
+		writeJSON(ctx, mutationHistoryResponse{TenantID: tenantID, DossierID: dossierID, Mutations: d.Mutations})
+	case resource == "situation" && ctx.IsGet():
+		d, ok := getDossier(ctx, st, tenantID, dossierID)
+		if !ok {
+			return
+		}
+		writeJSON(ctx, d.EndSituation)
+	case resource == "retroactive-changes" && ctx.IsPost():
+		storedRetroactive(ctx, st, tenantID, dossierID)
+	case resource == "mutations" || resource == "situation" || resource == "retroactive-changes":
+		writeError(ctx, 405, "Method not allowed")
+	default:
+		ctx.SetStatusCode(404)
+	}
+}
+
+func appendMutations(ctx *fasthttp.RequestCtx, st *store.Store, tenantID, dossierID string) {
+	var req appendMutationsRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Mutations) == 0 {
+		writeError(ctx, 400, "At least one mutation is required")
+		return
+	}
+
+	resp, err := st.Append(tenantID, dossierID, req.Mutations)
+	switch {
+	case errors.Is(err, store.ErrDuplicateMutation), errors.Is(err, store.ErrDossierMismatch):
+		writeError(ctx, 409, err.Error())
+		return
+	case err != nil:
+		writeError(ctx, 500, "Failed to store dossier: "+err.Error())
+		return
+	}
+	writeJSON(ctx, resp)
+}
+
+func getDossier(ctx *fasthttp.RequestCtx, st *store.Store, tenantID, dossierID string) (store.Dossier, bool) {
+	d, err := st.Get(tenantID, dossierID)
+	if err != nil {
+		writeError(ctx, 404, "Dossier "+dossierID+" not found for tenant "+tenantID)
+		return d, false
+	}
+	return d, true
+}
@@ -1,13 +1,48 @@
 package handler
 
 import (
+	"strings"
+
 	json "github.com/goccy/go-json"
 	"github.com/valyala/fasthttp"
 
 	"pension-engine/internal/engine"
 	"pension-engine/internal/model"
+	"pension-engine/internal/store"
 )
 
+// NewRouter returns the server's request handler. The stored-dossier
+// endpoints are only served when st is non-nil, and the admin endpoints only
+// when adminToken is set, to requests bearing it; unknown paths fall through
+// to HandleCalculation.
+func NewRouter(st *store.Store, adminToken string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		path := string(ctx.Path())
+		switch {
+		case path == "/calculation-requests/batch":
+			HandleBatch(ctx)
+		case path == "/situation-reconstructions":
+			HandleReconstruction(ctx)
+		case path == "/retroactive-recalculations":
+			HandleRetroactive(ctx)
+		case path == "/scenario-calculations":
+			HandleScenarios(ctx)
+		case path == schemeCachePath || strings.HasPrefix(path, schemeCachePath+"/"):
+			if authorizeAdmin(ctx, adminToken) {
+				handleSchemeCache(ctx, path)
+			}
+		case path == metricsPath:
+			if authorizeAdmin(ctx, adminToken) {
+				handleMetrics(ctx)
+			}
+		case st != nil && strings.HasPrefix(path, "/tenants/"):
+			handleDossier(ctx, st, path)
+		default:
+			HandleCalculation(ctx)
+		}
+	}
+}
+
 func HandleCalculation(ctx *fasthttp.RequestCtx) {
 	if !ctx.IsPost() {
 		writeError(ctx, 400, "Method not allowed")
@@ -32,8 +67,12 @@ func HandleCalculation(ctx *fasthttp.RequestCtx) {
 
 	resp := engine.Process(&req)
 
+	writeJSON(ctx, resp)
+}
+
+func writeJSON(ctx *fasthttp.RequestCtx, v interface{}) {
 	ctx.SetContentType("application/json")
-	body, _ := json.Marshal(resp)
+	body, _ := json.Marshal(v)
 	ctx.SetBody(body)
 }
 
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+)
+
+type reconstructionRequest struct {
+	CalculationResponse model.CalculationResponse `json:"calculation_response"`
+	MutationIndex       *int                      `json:"mutation_index"`
+	Direction           string                    `json:"direction,omitempty"`
+}
+
+// HandleReconstruction rebuilds the situation after a given mutation from
+// the patches of a previously returned CalculationResponse.
+func HandleReconstruction(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeError(ctx, 405, "Method not allowed")
+		return
+	}
+
+	var req reconstructionRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.MutationIndex == nil {
+		writeError(ctx, 400, "mutation_index is required")
+		return
+	}
+
+	env, err := engine.Reconstruct(&req.CalculationResponse.CalculationResult, *req.MutationIndex, req.Direction)
+	if err != nil {
+		status := 422
+		if errors.Is(err, engine.ErrMutationIndex) {
+			status = 400
+		}
+		writeError(ctx, status, "Cannot reconstruct situation: "+err.Error())
+		return
+	}
+	writeJSON(ctx, env)
+}
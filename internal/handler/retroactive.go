@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/retroactive"
+	"pension-engine/internal/store"
+)
+
+type retroactiveRequest struct {
+	model.CalculationRequest
+	retroactive.Change
+}
+
+type storedRetroactiveRequest struct {
+	retroactive.Change
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type storedRetroactiveResponse struct {
+	*retroactive.Result
+	Committed bool `json:"committed"`
+}
+
+// HandleRetroactive inserts or cancels a back-dated mutation in a supplied
+// mutation sequence and reports the impact of the recalculation.
+func HandleRetroactive(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeError(ctx, 405, "Method not allowed")
+		return
+	}
+
+	var req retroactiveRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(req.CalculationInstructions.Mutations) == 0 {
+		writeError(ctx, 400, "At least one mutation is required")
+		return
+	}
+
+	res, err := retroactive.Apply(&req.CalculationRequest, req.Change)
+	if err != nil {
+		writeRetroactiveError(ctx, err)
+		return
+	}
+	writeJSON(ctx, res)
+}
+
+// storedRetroactive applies a back-dated change to a stored dossier. Unless
+// dry_run is set, the corrected history replaces the stored one when its
+// recalculation succeeds.
+func storedRetroactive(ctx *fasthttp.RequestCtx, st *store.Store, tenantID, dossierID string) {
+	var req storedRetroactiveRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Insert != nil && req.Insert.DossierID != "" && req.Insert.DossierID != dossierID {
+		writeError(ctx, 409, store.ErrDossierMismatch.Error())
+		return
+	}
+
+	var res *retroactive.Result
+	err := st.Rewrite(tenantID, dossierID, func(history []model.Mutation) ([]model.Mutation, *model.CalculationResponse, error) {
+		var err error
+		res, err = retroactive.Apply(&model.CalculationRequest{
+			TenantID:                tenantID,
+			CalculationInstructions: model.CalculationInstructions{Mutations: history},
+		}, req.Change)
+		if err != nil || req.DryRun {
+			return nil, nil, err
+		}
+		return res.Mutations, res.Recalculated, nil
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(ctx, 404, "Dossier "+dossierID+" not found for tenant "+tenantID)
+		return
+	case errors.Is(err, store.ErrDossierMismatch):
+		writeError(ctx, 409, err.Error())
+		return
+	case err != nil:
+		writeRetroactiveError(ctx, err)
+		return
+	}
+
+	committed := !req.DryRun && res.Recalculated.CalculationMetadata.CalculationOutcome == model.OutcomeSuccess
+	writeJSON(ctx, storedRetroactiveResponse{Result: res, Committed: committed})
+}
+
+func writeRetroactiveError(ctx *fasthttp.RequestCtx, err error) {
+	switch {
+	case errors.Is(err, retroactive.ErrNoChange), errors.Is(err, retroactive.ErrDuplicateID):
+		writeError(ctx, 400, err.Error())
+	case errors.Is(err, retroactive.ErrMutationNotFound):
+		writeError(ctx, 404, err.Error())
+	case errors.Is(err, retroactive.ErrShiftsPolicyIDs), errors.Is(err, retroactive.ErrEmptySequence):
+		writeError(ctx, 409, err.Error())
+	default:
+		writeError(ctx, 500, "Recalculation failed: "+err.Error())
+	}
+}
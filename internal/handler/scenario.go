@@ -0,0 +1,30 @@
+package handler
+
+import (
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+
+	"pension-engine/internal/scenario"
+)
+
+// HandleScenarios calculates a shared mutation prefix once and compares
+// the alternative suffixes built on top of it.
+func HandleScenarios(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeError(ctx, 405, "Method not allowed")
+		return
+	}
+
+	var req scenario.Request
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, 400, "Invalid request body: "+err.Error())
+		return
+	}
+
+	resp, err := scenario.Run(&req)
+	if err != nil {
+		writeError(ctx, 400, err.Error())
+		return
+	}
+	writeJSON(ctx, resp)
+}
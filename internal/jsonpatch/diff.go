@@ -0,0 +1,97 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// Diff returns a patch that turns document a into document b. Arrays are
+// compared element by element; surplus elements are added or removed at
+// the end.
+func Diff(a, b []byte) ([]Operation, error) {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return nil, err
+	}
+	ops := []Operation{}
+	diff("", va, vb, &ops)
+	return ops, nil
+}
+
+func diff(path string, a, b interface{}, ops *[]Operation) {
+	switch ca := a.(type) {
+	case map[string]interface{}:
+		if cb, ok := b.(map[string]interface{}); ok {
+			diffObject(path, ca, cb, ops)
+			return
+		}
+	case []interface{}:
+		if cb, ok := b.([]interface{}); ok {
+			diffArray(path, ca, cb, ops)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, Operation{Op: "replace", Path: path, Value: encode(b)})
+	}
+}
+
+func diffObject(path string, a, b map[string]interface{}, ops *[]Operation) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapeToken(k)
+		va, inA := a[k]
+		vb, inB := b[k]
+		switch {
+		case !inB:
+			*ops = append(*ops, Operation{Op: "remove", Path: p})
+		case !inA:
+			*ops = append(*ops, Operation{Op: "add", Path: p, Value: encode(vb)})
+		default:
+			diff(p, va, vb, ops)
+		}
+	}
+}
+
+func diffArray(path string, a, b []interface{}, ops *[]Operation) {
+	common := len(a)
+	if len(b) < common {
+		common = len(b)
+	}
+	for i := 0; i < common; i++ {
+		diff(path+"/"+strconv.Itoa(i), a[i], b[i], ops)
+	}
+	// Remove from the back so earlier indexes stay valid.
+	for i := len(a) - 1; i >= common; i-- {
+		*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	for i := common; i < len(b); i++ {
+		*ops = append(*ops, Operation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: encode(b[i])})
+	}
+}
+
+func encode(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func escapeToken(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,341 @@
+// Package jsonpatch applies RFC 6902 JSON Patch documents to JSON values.
+package jsonpatch
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// Operation is a single RFC 6902 patch operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Document is a decoded JSON value that patches can be applied to
+// repeatedly without re-encoding in between.
+type Document struct {
+	root interface{}
+}
+
+// Parse decodes a JSON document.
+func Parse(b []byte) (*Document, error) {
+	var root interface{}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	return &Document{root: root}, nil
+}
+
+// Marshal encodes the current state of the document.
+func (d *Document) Marshal() ([]byte, error) {
+	return json.Marshal(d.root)
+}
+
+// Decode unmarshals the current state of the document into v.
+func (d *Document) Decode(v interface{}) error {
+	b, err := d.Marshal()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Apply applies an encoded patch document. Operations are applied in
+// order; on error the document may be partially patched.
+func (d *Document) Apply(patch []byte) error {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return err
+	}
+	for i := range ops {
+		if err := d.applyOp(&ops[i]); err != nil {
+			return &OpError{Index: i, Op: ops[i].Op, Path: ops[i].Path, Err: err}
+		}
+	}
+	return nil
+}
+
+// Apply applies patch to doc and returns the patched document.
+func Apply(doc, patch []byte) ([]byte, error) {
+	d, err := Parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Apply(patch); err != nil {
+		return nil, err
+	}
+	return d.Marshal()
+}
+
+var (
+	ErrPathNotFound  = errors.New("path not found")
+	ErrInvalidPath   = errors.New("invalid JSON pointer")
+	ErrInvalidIndex  = errors.New("invalid array index")
+	ErrTestFailed    = errors.New("test operation failed")
+	ErrUnsupportedOp = errors.New("unsupported operation")
+)
+
+// OpError reports which operation of a patch failed.
+type OpError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	return "operation " + strconv.Itoa(e.Index) + " (" + e.Op + " " + e.Path + "): " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+func (d *Document) applyOp(op *Operation) error {
+	switch op.Op {
+	case "add":
+		v, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		return d.add(op.Path, v)
+	case "remove":
+		_, err := d.remove(op.Path)
+		return err
+	case "replace":
+		v, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := d.remove(op.Path); err != nil {
+			return err
+		}
+		return d.add(op.Path, v)
+	case "move":
+		v, err := d.remove(op.From)
+		if err != nil {
+			return err
+		}
+		return d.add(op.Path, v)
+	case "copy":
+		v, err := d.get(op.From)
+		if err != nil {
+			return err
+		}
+		return d.add(op.Path, deepCopy(v))
+	case "test":
+		want, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		got, err := d.get(op.Path)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return ErrTestFailed
+		}
+		return nil
+	}
+	return ErrUnsupportedOp
+}
+
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("missing value")
+	}
+	var v interface{}
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// parsePointer splits an RFC 6901 pointer into unescaped reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, ErrInvalidPath
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func (d *Document) get(path string) (interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := d.root
+	for _, t := range tokens {
+		cur, err = child(cur, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+func child(v interface{}, token string) (interface{}, error) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		val, ok := c[token]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		return val, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		return c[i], nil
+	}
+	return nil, ErrPathNotFound
+}
+
+// arrayIndex parses token as an index in [0, max].
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrInvalidIndex
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, ErrInvalidIndex
+	}
+	return i, nil
+}
+
+// parentOf resolves the container holding the last token of path.
+func (d *Document) parentOf(path string) (interface{}, string, []string, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, "", nil, nil
+	}
+	cur := d.root
+	for _, t := range tokens[:len(tokens)-1] {
+		cur, err = child(cur, t)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	return cur, tokens[len(tokens)-1], tokens[:len(tokens)-1], nil
+}
+
+func (d *Document) add(path string, v interface{}) error {
+	parent, last, parentTokens, err := d.parentOf(path)
+	if err != nil {
+		return err
+	}
+	if parent == nil && last == "" {
+		d.root = v
+		return nil
+	}
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[last] = v
+		return nil
+	case []interface{}:
+		i := len(c)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(c)); err != nil {
+				return err
+			}
+		}
+		c = append(c, nil)
+		copy(c[i+1:], c[i:])
+		c[i] = v
+		return d.set(parentTokens, c)
+	}
+	return ErrPathNotFound
+}
+
+func (d *Document) remove(path string) (interface{}, error) {
+	parent, last, parentTokens, err := d.parentOf(path)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil && last == "" {
+		old := d.root
+		d.root = nil
+		return old, nil
+	}
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		old, ok := c[last]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		delete(c, last)
+		return old, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		old := c[i]
+		c = append(c[:i], c[i+1:]...)
+		return old, d.set(parentTokens, c)
+	}
+	return nil, ErrPathNotFound
+}
+
+// set stores a (possibly reallocated) array back at the given location.
+func (d *Document) set(tokens []string, v interface{}) error {
+	if len(tokens) == 0 {
+		d.root = v
+		return nil
+	}
+	cur := d.root
+	for _, t := range tokens[:len(tokens)-1] {
+		var err error
+		if cur, err = child(cur, t); err != nil {
+			return err
+		}
+	}
+	last := tokens[len(tokens)-1]
+	switch c := cur.(type) {
+	case map[string]interface{}:
+		c[last] = v
+		return nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(c)-1)
+		if err != nil {
+			return err
+		}
+		c[i] = v
+		return nil
+	}
+	return ErrPathNotFound
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, val := range c {
+			m[k] = deepCopy(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(c))
+		for i, val := range c {
+			s[i] = deepCopy(val)
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,88 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name, doc, patch, want string
+	}{
+		{"replace root member", `{"dossier":null}`, `[{"op":"replace","path":"/dossier","value":{"a":1}}]`, `{"dossier":{"a":1}}`},
+		{"add to object", `{"a":1}`, `[{"op":"add","path":"/b","value":[1,2]}]`, `{"a":1,"b":[1,2]}`},
+		{"insert shifts array", `{"p":[1,3]}`, `[{"op":"add","path":"/p/1","value":2}]`, `{"p":[1,2,3]}`},
+		{"append with dash", `{"p":[1]}`, `[{"op":"add","path":"/p/-","value":2}]`, `{"p":[1,2]}`},
+		{"remove shifts array", `{"p":[1,2,3]}`, `[{"op":"remove","path":"/p/0"}]`, `{"p":[2,3]}`},
+		{"nested replace", `{"d":{"p":[{"s":1}]}}`, `[{"op":"replace","path":"/d/p/0/s","value":2.5}]`, `{"d":{"p":[{"s":2.5}]}}`},
+		{"escaped tokens", `{"a/b":{"c~d":1}}`, `[{"op":"replace","path":"/a~1b/c~0d","value":2}]`, `{"a/b":{"c~d":2}}`},
+		{"move", `{"a":1,"b":{}}`, `[{"op":"move","from":"/a","path":"/b/a"}]`, `{"b":{"a":1}}`},
+		{"copy", `{"a":[1]}`, `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":[1],"b":[1]}`},
+		{"test passes", `{"a":1}`, `[{"op":"test","path":"/a","value":1}]`, `{"a":1}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	cases := []struct {
+		name, doc, patch string
+		want             error
+	}{
+		{"replace missing member", `{"a":1}`, `[{"op":"replace","path":"/b","value":1}]`, ErrPathNotFound},
+		{"remove out of range", `{"p":[1]}`, `[{"op":"remove","path":"/p/1"}]`, ErrInvalidIndex},
+		{"leading zero index", `{"p":[1,2]}`, `[{"op":"remove","path":"/p/01"}]`, ErrInvalidIndex},
+		{"test fails", `{"a":1}`, `[{"op":"test","path":"/a","value":2}]`, ErrTestFailed},
+		{"unknown op", `{"a":1}`, `[{"op":"merge","path":"/a","value":2}]`, ErrUnsupportedOp},
+		{"relative pointer", `{"a":1}`, `[{"op":"remove","path":"a"}]`, ErrInvalidPath},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	a := `{"dossier":{"status":"ACTIVE","policies":[{"salary":1,"projections":null},{"salary":2}],"x~/y":1}}`
+	b := `{"dossier":{"status":"RETIRED","policies":[{"salary":1,"projections":[{"p":1}]}],"z":true}}`
+
+	ops, err := Diff([]byte(a), []byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := encode(ops)
+	got, err := Apply([]byte(a), patch)
+	if err != nil {
+		t.Fatalf("applying %s: %v", patch, err)
+	}
+	if string(got) != string(normalize(t, b)) {
+		t.Fatalf("expected %s, got %s", b, got)
+	}
+
+	if ops, _ := Diff([]byte(a), []byte(a)); len(ops) != 0 {
+		t.Fatalf("expected empty diff for equal documents, got %v", ops)
+	}
+}
+
+func normalize(t *testing.T, s string) []byte {
+	t.Helper()
+	d, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := d.Marshal()
+	return b
+}
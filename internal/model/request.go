@@ -3,12 +3,15 @@ package model
 import "encoding/json"
 
 type CalculationRequest struct {
-	TenantID               string                  `json:"tenant_id"`
+	TenantID                string                  `json:"tenant_id"`
 	CalculationInstructions CalculationInstructions `json:"calculation_instructions"`
 }
 
 type CalculationInstructions struct {
 	Mutations []Mutation `json:"mutations"`
+	// InitialSituation optionally seeds the calculation with a previously
+	// calculated situation. When nil the engine starts from an empty dossier.
+	InitialSituation *InitialSituation `json:"initial_situation,omitempty"`
 }
 
 type Mutation struct {
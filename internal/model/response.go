@@ -8,12 +8,15 @@ type CalculationResponse struct {
 }
 
 type CalculationMetadata struct {
-	CalculationID        string `json:"calculation_id"`
-	TenantID             string `json:"tenant_id"`
-	CalculationStartedAt string `json:"calculation_started_at"`
+	CalculationID          string `json:"calculation_id"`
+	TenantID               string `json:"tenant_id"`
+	CalculationStartedAt   string `json:"calculation_started_at"`
 	CalculationCompletedAt string `json:"calculation_completed_at"`
-	CalculationDurationMs int64  `json:"calculation_duration_ms"`
-	CalculationOutcome   string `json:"calculation_outcome"`
+	CalculationDurationMs  int64  `json:"calculation_duration_ms"`
+	CalculationOutcome     string `json:"calculation_outcome"`
+	// SchemeRegistryVersion identifies the scheme definitions used, when the
+	// scheme provider versions them.
+	SchemeRegistryVersion string `json:"scheme_registry_version,omitempty"`
 }
 
 type CalculationResult struct {
@@ -24,10 +27,10 @@ type CalculationResult struct {
 }
 
 type ProcessedMutation struct {
-	Mutation                  Mutation         `json:"mutation"`
-	ForwardPatch              json.RawMessage  `json:"forward_patch_to_situation_after_this_mutation"`
-	BackwardPatch             json.RawMessage  `json:"backward_patch_to_previous_situation"`
-	CalculationMessageIndexes []int            `json:"calculation_message_indexes,omitempty"`
+	Mutation                  Mutation        `json:"mutation"`
+	ForwardPatch              json.RawMessage `json:"forward_patch_to_situation_after_this_mutation"`
+	BackwardPatch             json.RawMessage `json:"backward_patch_to_previous_situation"`
+	CalculationMessageIndexes []int           `json:"calculation_message_indexes,omitempty"`
 }
 
 type SituationEnvelope struct {
@@ -35,11 +38,17 @@ type SituationEnvelope struct {
 	MutationIndex int       `json:"mutation_index"`
 	ActualAt      string    `json:"actual_at"`
 	Situation     Situation `json:"situation"`
+	// PolicySeq carries the hidden Dossier.PolicySeq so that feeding the
+	// envelope back as an InitialSituation never reuses a removed policy ID.
+	PolicySeq int `json:"policy_seq,omitempty"`
 }
 
 type InitialSituation struct {
 	ActualAt  string    `json:"actual_at"`
 	Situation Situation `json:"situation"`
+	// PolicySeq carries the hidden Dossier.PolicySeq, which the situation
+	// itself never serialises, so policy numbering survives a round-trip.
+	PolicySeq int `json:"policy_seq,omitempty"`
 }
 
 type ErrorResponse struct {
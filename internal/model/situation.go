@@ -1,5 +1,13 @@
 package model
 
+// Dossier statuses. A dossier starts ACTIVE, becomes RETIRED through
+// calculate_retirement_benefit and DECEASED through register_death.
+const (
+	StatusActive   = "ACTIVE"
+	StatusRetired  = "RETIRED"
+	StatusDeceased = "DECEASED"
+)
+
 type Situation struct {
 	Dossier *Dossier `json:"dossier"`
 }
@@ -14,23 +22,137 @@ type Dossier struct {
 }
 
 type Person struct {
-	PersonID  string `json:"person_id"`
-	Role      string `json:"role"`
-	Name      string `json:"name"`
-	BirthDate string `json:"birth_date"`
+	PersonID    string  `json:"person_id"`
+	Role        string  `json:"role"`
+	Name        string  `json:"name"`
+	BirthDate   string  `json:"birth_date"`
+	DateOfDeath *string `json:"date_of_death,omitempty"`
+	// SurvivorBenefit is the annual survivor pension payable to a partner
+	// once the participant has died.
+	SurvivorBenefit *float64 `json:"survivor_benefit,omitempty"`
+	// PensionEntitlements are an ex-partner's shares of the participant's
+	// policies, recorded by divorce_split.
+	PensionEntitlements []PensionEntitlement `json:"pension_entitlements,omitempty"`
+}
+
+// PensionEntitlement is the annual pension an ex-partner receives from one
+// policy. It is deducted from the policy's attainable_pension at retirement.
+type PensionEntitlement struct {
+	PolicyID string  `json:"policy_id"`
+	Amount   float64 `json:"amount"`
 }
 
 type Policy struct {
-	PolicyID            string       `json:"policy_id"`
-	SchemeID            string       `json:"scheme_id"`
-	EmploymentStartDate string       `json:"employment_start_date"`
-	Salary              float64      `json:"salary"`
-	PartTimeFactor      float64      `json:"part_time_factor"`
-	AttainablePension   *float64     `json:"attainable_pension"`
-	Projections         []Projection `json:"projections"`
+	PolicyID            string          `json:"policy_id"`
+	SchemeID            string          `json:"scheme_id"`
+	EmploymentStartDate string          `json:"employment_start_date"`
+	EmploymentEndDate   *string         `json:"employment_end_date,omitempty"`
+	Salary              float64         `json:"salary"`
+	PartTimeFactor      float64         `json:"part_time_factor"`
+	SalaryHistory       []SalarySegment `json:"salary_history,omitempty"`
+	CapitalBalance      *float64        `json:"capital_balance,omitempty"`
+	AttainablePension   *float64        `json:"attainable_pension"`
+	SurvivorPension     *float64        `json:"survivor_pension,omitempty"`
+	CommutedPension     *float64        `json:"commuted_pension,omitempty"`
+	LumpSum             *float64        `json:"lump_sum,omitempty"`
+	Projections         []Projection    `json:"projections"`
+}
+
+// SalarySegment is the salary and part-time factor in effect from StartDate
+// until the StartDate of the next segment. A policy only carries a history
+// once its salary or part-time factor has been changed; the first segment
+// then starts at the employment start date and the last one always matches
+// the policy's Salary and PartTimeFactor.
+type SalarySegment struct {
+	StartDate      string  `json:"start_date"`
+	Salary         float64 `json:"salary"`
+	PartTimeFactor float64 `json:"part_time_factor"`
 }
 
 type Projection struct {
 	Date             string  `json:"date"`
 	ProjectedPension float64 `json:"projected_pension"`
+	// ProjectedCapital is only set for policies of a DC scheme.
+	ProjectedCapital *float64 `json:"projected_capital,omitempty"`
+}
+
+// Clone returns a deep copy of the situation, so a caller can keep a
+// situation around while the engine mutates its own copy.
+func (s Situation) Clone() Situation {
+	if s.Dossier == nil {
+		return Situation{}
+	}
+	d := *s.Dossier
+	if s.Dossier.RetirementDate != nil {
+		rd := *s.Dossier.RetirementDate
+		d.RetirementDate = &rd
+	}
+	if s.Dossier.Persons != nil {
+		d.Persons = make([]Person, len(s.Dossier.Persons))
+		for i, p := range s.Dossier.Persons {
+			d.Persons[i] = p.clone()
+		}
+	}
+	if s.Dossier.Policies != nil {
+		d.Policies = make([]Policy, len(s.Dossier.Policies))
+		for i, p := range s.Dossier.Policies {
+			d.Policies[i] = p.clone()
+		}
+	}
+	return Situation{Dossier: &d}
+}
+
+func (p Person) clone() Person {
+	if p.DateOfDeath != nil {
+		dd := *p.DateOfDeath
+		p.DateOfDeath = &dd
+	}
+	if p.SurvivorBenefit != nil {
+		sb := *p.SurvivorBenefit
+		p.SurvivorBenefit = &sb
+	}
+	if p.PensionEntitlements != nil {
+		p.PensionEntitlements = append(make([]PensionEntitlement, 0, len(p.PensionEntitlements)), p.PensionEntitlements...)
+	}
+	return p
+}
+
+func (p Policy) clone() Policy {
+	if p.EmploymentEndDate != nil {
+		ed := *p.EmploymentEndDate
+		p.EmploymentEndDate = &ed
+	}
+	if p.AttainablePension != nil {
+		ap := *p.AttainablePension
+		p.AttainablePension = &ap
+	}
+	if p.SurvivorPension != nil {
+		sp := *p.SurvivorPension
+		p.SurvivorPension = &sp
+	}
+	if p.CommutedPension != nil {
+		cp := *p.CommutedPension
+		p.CommutedPension = &cp
+	}
+	if p.LumpSum != nil {
+		ls := *p.LumpSum
+		p.LumpSum = &ls
+	}
+	if p.CapitalBalance != nil {
+		cb := *p.CapitalBalance
+		p.CapitalBalance = &cb
+	}
+	if p.SalaryHistory != nil {
+		p.SalaryHistory = append(make([]SalarySegment, 0, len(p.SalaryHistory)), p.SalaryHistory...)
+	}
+	if p.Projections != nil {
+		p.Projections = append(make([]Projection, 0, len(p.Projections)), p.Projections...)
+		for i := range p.Projections {
+			if c := p.Projections[i].ProjectedCapital; c != nil {
+				pc := *c
+				p.Projections[i].ProjectedCapital = &pc
+			}
+		}
+	}
+	return p
 }
@@ -0,0 +1,79 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type addContributionProps struct {
+	PolicyID string  `json:"policy_id"`
+	Amount   float64 `json:"amount"`
+}
+
+// AddContributionHandler adds a contribution to the capital balance of a
+// policy in a DC scheme.
+type AddContributionHandler struct{}
+
+func (h *AddContributionHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props addContributionProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	idx := -1
+	for i := range state.Dossier.Policies {
+		if state.Dossier.Policies[i].PolicyID == props.PolicyID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "POLICY_NOT_FOUND",
+			Message: "Policy " + props.PolicyID + " does not exist",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	if props.Amount <= 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_CONTRIBUTION_AMOUNT",
+			Message: "Contribution amount must be greater than 0",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	p := &state.Dossier.Policies[idx]
+	sc := provider.GetSchemes([]string{p.SchemeID}, schemeregistry.DateRange{})[p.SchemeID]
+	if !sc.IsDC() {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "POLICY_NOT_DC",
+			Message: "Policy " + props.PolicyID + " belongs to DB scheme " + p.SchemeID,
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	path := "/dossier/policies/" + strconv.Itoa(idx) + "/capital_balance"
+	var bwd []byte
+	balance := props.Amount
+	if p.CapitalBalance == nil {
+		bwd = marshalPatches([]patchOp{{Op: "remove", Path: path}})
+	} else {
+		bwd = marshalPatches([]patchOp{{Op: "replace", Path: path, Value: marshalValue(*p.CapitalBalance)}})
+		balance += *p.CapitalBalance
+	}
+	p.CapitalBalance = &balance
+
+	fwd := marshalPatches([]patchOp{{Op: "add", Path: path, Value: marshalValue(balance)}})
+	return nil, false, fwd, bwd
+}
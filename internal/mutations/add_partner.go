@@ -0,0 +1,85 @@
+package mutations
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type addPartnerProps struct {
+	PersonID  string `json:"person_id"`
+	Name      string `json:"name"`
+	BirthDate string `json:"birth_date"`
+}
+
+// AddPartnerHandler registers the participant's partner. A dossier has at
+// most one partner.
+type AddPartnerHandler struct{}
+
+func (h *AddPartnerHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	if personIndex(state.Dossier, rolePartner) >= 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "PARTNER_ALREADY_EXISTS",
+			Message: "The dossier already has a partner",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props addPartnerProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	for _, p := range state.Dossier.Persons {
+		if p.PersonID == props.PersonID {
+			return []model.CalculationMessage{{
+				Level:   model.LevelCritical,
+				Code:    "DUPLICATE_PERSON_ID",
+				Message: "A person with person_id " + props.PersonID + " already exists",
+			}}, true, emptyPatch, emptyPatch
+		}
+	}
+
+	if strings.TrimSpace(props.Name) == "" {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_NAME",
+			Message: "Name is empty or blank",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	t, ok := fastParseDate(props.BirthDate)
+	if !ok || t.After(time.Now()) {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_BIRTH_DATE",
+			Message: "Birth date is invalid or in the future",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	// Apply
+	state.Dossier.Persons = append(state.Dossier.Persons, model.Person{
+		PersonID:  props.PersonID,
+		Role:      rolePartner,
+		Name:      props.Name,
+		BirthDate: props.BirthDate,
+	})
+
+	idx := len(state.Dossier.Persons) - 1
+	path := "/dossier/persons/" + strconv.Itoa(idx)
+	fwd := marshalPatches([]patchOp{{Op: "add", Path: path, Value: marshalValue(state.Dossier.Persons[idx])}})
+	bwd := marshalPatches([]patchOp{{Op: "remove", Path: path}})
+
+	return nil, false, fwd, bwd
+}
@@ -6,6 +6,7 @@ import (
 	json "github.com/goccy/go-json"
 
 	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
 )
 
 type addPolicyProps struct {
@@ -17,7 +18,7 @@ type addPolicyProps struct {
 
 type AddPolicyHandler struct{}
 
-func (h *AddPolicyHandler) Execute(state *model.Situation, mutation *model.Mutation) ([]model.CalculationMessage, bool, []byte, []byte) {
+func (h *AddPolicyHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
 	if state.Dossier == nil {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
@@ -6,6 +6,7 @@ import (
 	json "github.com/goccy/go-json"
 
 	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
 )
 
 type applyIndexationProps struct {
@@ -16,7 +17,7 @@ type applyIndexationProps struct {
 
 type ApplyIndexationHandler struct{}
 
-func (h *ApplyIndexationHandler) Execute(state *model.Situation, mutation *model.Mutation) ([]model.CalculationMessage, bool, []byte, []byte) {
+func (h *ApplyIndexationHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
 	if state.Dossier == nil {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
@@ -41,10 +42,13 @@ func (h *ApplyIndexationHandler) Execute(state *model.Situation, mutation *model
 
 	var fwdOps, bwdOps []patchOp
 
+	// Policies of a scheme without indexation never match
+	schemes := provider.GetSchemes(uniqueSchemeIDs(state.Dossier.Policies), schemeregistry.DateRange{})
+
 	// Single pass: validate filter match AND apply indexation
 	matched := false
 	for i := range state.Dossier.Policies {
-		if !matchesFilter(state.Dossier.Policies[i], props) {
+		if !matchesFilter(state.Dossier.Policies[i], props) || schemes[state.Dossier.Policies[i].SchemeID].Indexation == schemeregistry.IndexationNone {
 			continue
 		}
 		matched = true
@@ -63,6 +67,17 @@ func (h *ApplyIndexationHandler) Execute(state *model.Situation, mutation *model
 		path := "/dossier/policies/" + strconv.Itoa(i) + "/salary"
 		fwdOps = append(fwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(newSalary)})
 		bwdOps = append(bwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(oldSalary)})
+
+		// Indexation applies to the whole salary history, as it does to a
+		// policy without one.
+		if oldHistory := state.Dossier.Policies[i].SalaryHistory; len(oldHistory) > 0 {
+			newHistory := indexedHistory(oldHistory, 1+props.Percentage)
+			state.Dossier.Policies[i].SalaryHistory = newHistory
+
+			path := "/dossier/policies/" + strconv.Itoa(i) + "/salary_history"
+			fwdOps = append(fwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(newHistory)})
+			bwdOps = append(bwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(oldHistory)})
+		}
 	}
 
 	if hasFilter && !matched {
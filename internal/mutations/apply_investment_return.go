@@ -0,0 +1,67 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type applyInvestmentReturnProps struct {
+	Percentage float64 `json:"percentage"`
+	SchemeID   string  `json:"scheme_id,omitempty"`
+}
+
+// ApplyInvestmentReturnHandler grows (or shrinks) the capital balance of
+// every DC policy holding capital by a percentage, optionally only for one
+// scheme.
+type ApplyInvestmentReturnHandler struct{}
+
+func (h *ApplyInvestmentReturnHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props applyInvestmentReturnProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	policies := state.Dossier.Policies
+	schemes := provider.GetSchemes(uniqueSchemeIDs(policies), schemeregistry.DateRange{})
+
+	var fwdOps, bwdOps []patchOp
+	for i := range policies {
+		p := &policies[i]
+		if p.CapitalBalance == nil || !schemes[p.SchemeID].IsDC() {
+			continue
+		}
+		if props.SchemeID != "" && p.SchemeID != props.SchemeID {
+			continue
+		}
+		oldBalance := *p.CapitalBalance
+		balance := oldBalance * (1 + props.Percentage)
+		if balance < 0 {
+			balance = 0
+		}
+		p.CapitalBalance = &balance
+
+		path := "/dossier/policies/" + strconv.Itoa(i) + "/capital_balance"
+		fwdOps = append(fwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(balance)})
+		bwdOps = append(bwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(oldBalance)})
+	}
+
+	if len(fwdOps) == 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelWarning,
+			Code:    "NO_MATCHING_POLICIES",
+			Message: "No DC policies with a capital balance match the provided filter criteria",
+		}}, false, emptyPatch, emptyPatch
+	}
+
+	return nil, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
+}
@@ -17,7 +17,7 @@ type calcRetirementProps struct {
 
 type CalculateRetirementBenefitHandler struct{}
 
-func (h *CalculateRetirementBenefitHandler) Execute(state *model.Situation, mutation *model.Mutation) ([]model.CalculationMessage, bool, []byte, []byte) {
+func (h *CalculateRetirementBenefitHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
 	if state.Dossier == nil {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
@@ -38,30 +38,31 @@ func (h *CalculateRetirementBenefitHandler) Execute(state *model.Situation, muta
 	json.Unmarshal(mutation.MutationProperties, &props)
 
 	retDate, _ := fastParseDate(props.RetirementDate)
-	birthDate, _ := fastParseDate(state.Dossier.Persons[0].BirthDate)
+	participant := personIndex(state.Dossier, roleParticipant)
+	if participant < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "PARTICIPANT_NOT_FOUND",
+			Message: "Dossier has no participant",
+		}}, true, emptyPatch, emptyPatch
+	}
+	birthDate, _ := fastParseDate(state.Dossier.Persons[participant].BirthDate)
 
 	policies := state.Dossier.Policies
 	n := len(policies)
 
-	// Single pass: parse dates, compute years of service and effective salaries
-	age := calendarYears(birthDate, retDate)
-	years := make([]float64, n)
-	effectiveSalaries := make([]float64, n)
-	var totalYears float64
+	// Fetch per-scheme parameters
+	uniqueSchemes := uniqueSchemeIDs(policies)
+	schemes := provider.GetSchemes(uniqueSchemes, schemeregistry.DateRange{To: retDate})
 
-	for i, p := range policies {
-		empStart, _ := fastParseDate(p.EmploymentStartDate)
-		y := daysBetween(empStart, retDate) / 365.25
-		if y < 0 {
-			y = 0
-		}
-		years[i] = y
-		effectiveSalaries[i] = p.Salary * p.PartTimeFactor
-		totalYears += y
-	}
+	age := calendarYears(birthDate, retDate)
+	years, accrued, totalYears := accrue(policies, schemes, retDate)
 
-	// Eligibility: must be >= 65 years old OR total years of service >= 40
-	if age < 65 && totalYears < 40 {
+	// Eligibility per scheme: the minimum retirement age or the minimum
+	// years of service (65 and 40 by default), combined across schemes per
+	// the eligibility mode.
+	ageMonths := calendarMonths(birthDate, retDate)
+	if !eligible(schemes, uniqueSchemes, ageMonths, totalYears) {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
 			Code:    "NOT_ELIGIBLE",
@@ -69,6 +70,17 @@ func (h *CalculateRetirementBenefitHandler) Execute(state *model.Situation, muta
 		}}, true, emptyPatch, emptyPatch
 	}
 
+	// DC capital is converted with the scheme's annuity factor
+	for _, id := range uniqueSchemes {
+		if sc := schemes[id]; sc.IsDC() && sc.AnnuityFactor <= 0 {
+			return []model.CalculationMessage{{
+				Level:   model.LevelCritical,
+				Code:    "ANNUITY_FACTOR_MISSING",
+				Message: "DC scheme " + id + " supplies no annuity factor",
+			}}, true, emptyPatch, emptyPatch
+		}
+	}
+
 	// Check retirement before employment (WARNING per violating policy)
 	var msgs []model.CalculationMessage
 	for _, p := range policies {
@@ -80,53 +92,201 @@ func (h *CalculateRetirementBenefitHandler) Execute(state *model.Situation, muta
 			})
 		}
 	}
+	msgs = append(msgs, salaryCappedWarnings(policies, schemes)...)
 
 	// Capture old state for backward patches
 	oldStatus := state.Dossier.Status
+	oldRetirementDate := marshalValue(state.Dossier.RetirementDate)
+	oldPensions := make([]json.RawMessage, n)
+	oldSurvivor := make([]*float64, n)
+	for i := range policies {
+		oldPensions[i] = marshalValue(policies[i].AttainablePension)
+		oldSurvivor[i] = policies[i].SurvivorPension
+	}
 
-	// Fetch per-scheme accrual rates
-	uniqueSchemes := uniqueSchemeIDs(policies)
-	rates := schemeregistry.GetAccrualRates(uniqueSchemes)
+	// Actuarial adjustment per scheme. Participants retiring early on the
+	// scheme's years-of-service rule keep their full pension, and the annuity
+	// factor of a DC scheme already accounts for the retirement age.
+	factors := make(map[string]float64, len(uniqueSchemes))
+	for _, id := range uniqueSchemes {
+		sc := schemes[id]
+		months := ageMonths - sc.NormalRetirementAge*12
+		if sc.IsDC() || (totalYears >= sc.MinServiceYears && months < 0) {
+			factors[id] = 1
+			continue
+		}
+		f := sc.AdjustmentFactor(ageMonths)
+		factors[id] = f
+		switch {
+		case f < 1:
+			msgs = append(msgs, model.CalculationMessage{
+				Level:   model.LevelWarning,
+				Code:    "EARLY_RETIREMENT_REDUCTION",
+				Message: fmt.Sprintf("Early retirement reduction factor %.4f applied for scheme %s (%d months before normal retirement age %d)", f, id, -months, sc.NormalRetirementAge),
+			})
+		case f > 1:
+			msgs = append(msgs, model.CalculationMessage{
+				Level:   model.LevelWarning,
+				Code:    "LATE_RETIREMENT_INCREASE",
+				Message: fmt.Sprintf("Late retirement increase factor %.4f applied for scheme %s (%d months after normal retirement age %d)", f, id, months, sc.NormalRetirementAge),
+			})
+		}
+	}
 
-	// Compute annual pension with per-scheme accrual rates
-	var annualPension float64
-	if totalYears > 0 {
-		for i := range policies {
-			rate := rates[policies[i].SchemeID]
-			annualPension += effectiveSalaries[i] * years[i] * rate
+	// Policies within their vesting period get no pension
+	unvested, vestedYears := vest(policies, schemes, years, accrued, totalYears)
+	pensions := distribute(policies, schemes, years, accrued, vestedYears)
+	for _, i := range unvested {
+		pensions[i] = 0
+		sc := schemes[policies[i].SchemeID]
+		msgs = append(msgs, model.CalculationMessage{
+			Level:   model.LevelWarning,
+			Code:    "POLICY_NOT_VESTED",
+			Message: fmt.Sprintf("Policy %s has not completed the vesting period of %.1f years of scheme %s", policies[i].PolicyID, sc.VestingYears, sc.SchemeID),
+		})
+	}
+
+	// Ex-partner entitlements from divorce_split are deducted after the
+	// actuarial adjustment
+	deductions := divorceDeductions(state.Dossier)
+	for i := range pensions {
+		pensions[i] *= factors[policies[i].SchemeID]
+		if d, ok := deductions[policies[i].PolicyID]; ok {
+			pensions[i] -= d
+			if pensions[i] < 0 {
+				pensions[i] = 0
+			}
+		}
+	}
+
+	// Survivor pension per policy, only when a partner is registered. It is
+	// based on the pension before any commutation.
+	hasPartner := personIndex(state.Dossier, rolePartner) >= 0
+	for i := range state.Dossier.Policies {
+		p := &state.Dossier.Policies[i]
+		if !hasPartner {
+			p.SurvivorPension = nil
+			continue
 		}
+		survivor := pensions[i] * schemes[p.SchemeID].SurvivorPercentage
+		p.SurvivorPension = &survivor
 	}
 
+	// A pension commuted earlier stays commuted when it is recalculated
 	for i := range state.Dossier.Policies {
-		var policyPension float64
-		if totalYears > 0 {
-			policyPension = annualPension * (years[i] / totalYears)
+		p := &state.Dossier.Policies[i]
+		policyPension := pensions[i]
+		if p.CommutedPension != nil {
+			policyPension -= *p.CommutedPension
+			if policyPension < 0 {
+				policyPension = 0
+			}
 		}
-		state.Dossier.Policies[i].AttainablePension = &policyPension
+		p.AttainablePension = &policyPension
 	}
 
-	state.Dossier.Status = "RETIRED"
+	state.Dossier.Status = model.StatusRetired
 	state.Dossier.RetirementDate = &props.RetirementDate
 
 	// Generate patches for: status, retirement_date, attainable_pension per policy
 	fwdOps := make([]patchOp, 0, 2+n)
 	bwdOps := make([]patchOp, 0, 2+n)
 
-	fwdOps = append(fwdOps, patchOp{Op: "replace", Path: "/dossier/status", Value: marshalValue("RETIRED")})
+	fwdOps = append(fwdOps, patchOp{Op: "replace", Path: "/dossier/status", Value: marshalValue(model.StatusRetired)})
 	bwdOps = append(bwdOps, patchOp{Op: "replace", Path: "/dossier/status", Value: marshalValue(oldStatus)})
 
 	fwdOps = append(fwdOps, patchOp{Op: "replace", Path: "/dossier/retirement_date", Value: marshalValue(props.RetirementDate)})
-	bwdOps = append(bwdOps, patchOp{Op: "replace", Path: "/dossier/retirement_date", Value: jsonNull})
+	bwdOps = append(bwdOps, patchOp{Op: "replace", Path: "/dossier/retirement_date", Value: oldRetirementDate})
 
 	for i := range state.Dossier.Policies {
-		path := "/dossier/policies/" + strconv.Itoa(i) + "/attainable_pension"
-		fwdOps = append(fwdOps, patchOp{Op: "replace", Path: path, Value: marshalValue(state.Dossier.Policies[i].AttainablePension)})
-		bwdOps = append(bwdOps, patchOp{Op: "replace", Path: path, Value: jsonNull})
+		base := "/dossier/policies/" + strconv.Itoa(i)
+		fwdOps = append(fwdOps, patchOp{Op: "replace", Path: base + "/attainable_pension", Value: marshalValue(state.Dossier.Policies[i].AttainablePension)})
+		bwdOps = append(bwdOps, patchOp{Op: "replace", Path: base + "/attainable_pension", Value: oldPensions[i]})
+
+		// survivor_pension is omitted while unset, so it is added and removed
+		// rather than replaced with null.
+		newSurvivor := state.Dossier.Policies[i].SurvivorPension
+		switch {
+		case newSurvivor != nil:
+			fwdOps = append(fwdOps, patchOp{Op: "add", Path: base + "/survivor_pension", Value: marshalValue(*newSurvivor)})
+		case oldSurvivor[i] != nil:
+			fwdOps = append(fwdOps, patchOp{Op: "remove", Path: base + "/survivor_pension"})
+		}
+		switch {
+		case oldSurvivor[i] != nil:
+			bwdOps = append(bwdOps, patchOp{Op: "add", Path: base + "/survivor_pension", Value: marshalValue(*oldSurvivor[i])})
+		case newSurvivor != nil:
+			bwdOps = append(bwdOps, patchOp{Op: "remove", Path: base + "/survivor_pension"})
+		}
 	}
 
 	return msgs, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
 }
 
+// accrue computes, per policy, the years of service and the accrued pension
+// (pensionable salary × years × accrual rate, per salary history segment and
+// accrual rate period) up to at.
+func accrue(policies []model.Policy, schemes map[string]schemeregistry.Scheme, at time.Time) (years, accrued []float64, totalYears float64) {
+	years = make([]float64, len(policies))
+	accrued = make([]float64, len(policies))
+	for i := range policies {
+		p := &policies[i]
+		sc := schemes[p.SchemeID]
+		empStart, _ := fastParseDate(p.EmploymentStartDate)
+		empEnd := employmentEnd(p)
+		years[i] = yearsOfService(empStart, empEnd, at)
+		accrued[i] = accruedPension(p, sc, salaryPeriods(p, sc), empStart, empEnd, at)
+		totalYears += years[i]
+	}
+	return years, accrued, totalYears
+}
+
+// salaryCappedWarnings returns a SALARY_CAPPED warning for every DB policy
+// whose salary exceeds its scheme's maximum pensionable salary.
+func salaryCappedWarnings(policies []model.Policy, schemes map[string]schemeregistry.Scheme) []model.CalculationMessage {
+	var msgs []model.CalculationMessage
+	for i := range policies {
+		sc := schemes[policies[i].SchemeID]
+		if sc.IsDC() || !salaryCapped(&policies[i], sc) {
+			continue
+		}
+		msgs = append(msgs, model.CalculationMessage{
+			Level:   model.LevelWarning,
+			Code:    "SALARY_CAPPED",
+			Message: fmt.Sprintf("Salary for policy %s capped at the maximum pensionable salary %.2f of scheme %s", policies[i].PolicyID, sc.MaxPensionableSalary, sc.SchemeID),
+		})
+	}
+	return msgs
+}
+
+// distribute sums the pension accrued on the policies into the annual
+// pension and splits it over the policies proportionally to their years of
+// service. Policies of a DC scheme take no part in that and get their capital
+// converted instead (see dcPension).
+func distribute(policies []model.Policy, schemes map[string]schemeregistry.Scheme, years, accrued []float64, totalYears float64) []float64 {
+	pensions := make([]float64, len(policies))
+	dbYears := totalYears
+	var annualPension float64
+	for i := range policies {
+		sc := schemes[policies[i].SchemeID]
+		if sc.IsDC() {
+			pensions[i] = dcPension(&policies[i], sc)
+			dbYears -= years[i]
+			continue
+		}
+		annualPension += accrued[i]
+	}
+	if dbYears <= 0 {
+		return pensions
+	}
+	for i := range policies {
+		if !schemes[policies[i].SchemeID].IsDC() {
+			pensions[i] = annualPension * (years[i] / dbYears)
+		}
+	}
+	return pensions
+}
+
 func uniqueSchemeIDs(policies []model.Policy) []string {
 	seen := make(map[string]struct{}, len(policies))
 	result := make([]string, 0, len(policies))
@@ -143,6 +303,29 @@ func daysBetween(start, end time.Time) float64 {
 	return end.Sub(start).Hours() / 24
 }
 
+// yearsOfService returns the service between start and at, capped at end
+// when the employment has been terminated (end is the zero time otherwise).
+func yearsOfService(start, end, at time.Time) float64 {
+	if !end.IsZero() && end.Before(at) {
+		at = end
+	}
+	y := daysBetween(start, at) / 365.25
+	if y < 0 {
+		return 0
+	}
+	return y
+}
+
+// employmentEnd returns the policy's employment end date, or the zero time
+// while the employment is ongoing.
+func employmentEnd(p *model.Policy) time.Time {
+	if p.EmploymentEndDate == nil {
+		return time.Time{}
+	}
+	t, _ := fastParseDate(*p.EmploymentEndDate)
+	return t
+}
+
 func calendarYears(birth, target time.Time) float64 {
 	years := float64(target.Year() - birth.Year())
 	if target.Month() < birth.Month() ||
@@ -151,3 +334,12 @@ func calendarYears(birth, target time.Time) float64 {
 	}
 	return years
 }
+
+// calendarMonths returns the number of full months between birth and target.
+func calendarMonths(birth, target time.Time) int {
+	months := (target.Year()-birth.Year())*12 + int(target.Month()-birth.Month())
+	if target.Day() < birth.Day() {
+		months--
+	}
+	return months
+}
@@ -0,0 +1,27 @@
+package mutations
+
+import (
+	"math"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+// dcPension converts the capital balance of a DC policy to an annual pension
+// with the scheme's annuity factor. Without capital or an annuity factor the
+// pension is 0.
+func dcPension(p *model.Policy, sc schemeregistry.Scheme) float64 {
+	if p.CapitalBalance == nil || sc.AnnuityFactor <= 0 {
+		return 0
+	}
+	return *p.CapitalBalance / sc.AnnuityFactor
+}
+
+// projectedCapital grows capital at the scheme's assumed yearly return over
+// years.
+func projectedCapital(capital float64, sc schemeregistry.Scheme, years float64) float64 {
+	if years <= 0 {
+		return capital
+	}
+	return capital * math.Pow(1+sc.AssumedReturnRate, years)
+}
@@ -0,0 +1,33 @@
+package mutations
+
+import (
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type changePartTimeFactorProps struct {
+	PolicyID       string  `json:"policy_id"`
+	EffectiveDate  string  `json:"effective_date"`
+	PartTimeFactor float64 `json:"part_time_factor"`
+}
+
+type ChangePartTimeFactorHandler struct{}
+
+func (h *ChangePartTimeFactorHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	var props changePartTimeFactorProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	if props.PartTimeFactor < 0 || props.PartTimeFactor > 1 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_PART_TIME_FACTOR",
+			Message: "Part-time factor must be between 0 and 1",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	return changePolicySalary(state, props.PolicyID, props.EffectiveDate, func(seg *model.SalarySegment) {
+		seg.PartTimeFactor = props.PartTimeFactor
+	})
+}
@@ -0,0 +1,33 @@
+package mutations
+
+import (
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type changeSalaryProps struct {
+	PolicyID      string  `json:"policy_id"`
+	EffectiveDate string  `json:"effective_date"`
+	Salary        float64 `json:"salary"`
+}
+
+type ChangeSalaryHandler struct{}
+
+func (h *ChangeSalaryHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	var props changeSalaryProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	if props.Salary < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_SALARY",
+			Message: "Salary must be non-negative",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	return changePolicySalary(state, props.PolicyID, props.EffectiveDate, func(seg *model.SalarySegment) {
+		seg.Salary = props.Salary
+	})
+}
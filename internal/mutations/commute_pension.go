@@ -0,0 +1,116 @@
+package mutations
+
+import (
+	"fmt"
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type commutePensionProps struct {
+	CommutationPercentage float64  `json:"commutation_percentage"`
+	AnnuityFactor         *float64 `json:"annuity_factor"`
+}
+
+// CommutePensionHandler exchanges commutation_percentage of every policy's
+// annual attainable_pension for a one-off lump sum of commuted pension ×
+// annuity factor. The annuity factor comes from the request or, when
+// omitted, from the policy's scheme.
+type CommutePensionHandler struct{}
+
+func (h *CommutePensionHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props commutePensionProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	if props.CommutationPercentage <= 0 || props.CommutationPercentage > 1 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_COMMUTATION_PERCENTAGE",
+			Message: "Commutation percentage must be greater than 0 and at most 1",
+		}}, true, emptyPatch, emptyPatch
+	}
+	if props.AnnuityFactor != nil && *props.AnnuityFactor <= 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_ANNUITY_FACTOR",
+			Message: "Annuity factor must be greater than 0",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	policies := state.Dossier.Policies
+	for _, p := range policies {
+		if p.LumpSum != nil {
+			return []model.CalculationMessage{{
+				Level:   model.LevelCritical,
+				Code:    "PENSION_ALREADY_COMMUTED",
+				Message: "Policy " + p.PolicyID + " has already been commuted",
+			}}, true, emptyPatch, emptyPatch
+		}
+	}
+
+	uniqueSchemes := uniqueSchemeIDs(policies)
+	schemes := provider.GetSchemes(uniqueSchemes, schemeregistry.DateRange{})
+	for _, id := range uniqueSchemes {
+		sc := schemes[id]
+		if props.CommutationPercentage > sc.MaxCommutationPercentage {
+			return []model.CalculationMessage{{
+				Level:   model.LevelCritical,
+				Code:    "COMMUTATION_EXCEEDS_MAXIMUM",
+				Message: fmt.Sprintf("Scheme %s allows commuting at most %.4f of the pension", id, sc.MaxCommutationPercentage),
+			}}, true, emptyPatch, emptyPatch
+		}
+		if props.AnnuityFactor == nil && sc.AnnuityFactor <= 0 {
+			return []model.CalculationMessage{{
+				Level:   model.LevelCritical,
+				Code:    "ANNUITY_FACTOR_MISSING",
+				Message: "Scheme " + id + " supplies no annuity factor and none was given",
+			}}, true, emptyPatch, emptyPatch
+		}
+	}
+
+	// Policies added after retirement have no attainable_pension to commute
+	var fwdOps, bwdOps []patchOp
+	for i := range policies {
+		p := &policies[i]
+		if p.AttainablePension == nil {
+			continue
+		}
+		factor := schemes[p.SchemeID].AnnuityFactor
+		if props.AnnuityFactor != nil {
+			factor = *props.AnnuityFactor
+		}
+
+		oldPension := *p.AttainablePension
+		commuted := oldPension * props.CommutationPercentage
+		pension := oldPension - commuted
+		lumpSum := commuted * factor
+		p.AttainablePension = &pension
+		p.CommutedPension = &commuted
+		p.LumpSum = &lumpSum
+
+		base := "/dossier/policies/" + strconv.Itoa(i)
+		fwdOps = append(fwdOps,
+			patchOp{Op: "replace", Path: base + "/attainable_pension", Value: marshalValue(pension)},
+			patchOp{Op: "add", Path: base + "/commuted_pension", Value: marshalValue(commuted)},
+			patchOp{Op: "add", Path: base + "/lump_sum", Value: marshalValue(lumpSum)},
+		)
+		bwdOps = append(bwdOps,
+			patchOp{Op: "replace", Path: base + "/attainable_pension", Value: marshalValue(oldPension)},
+			patchOp{Op: "remove", Path: base + "/commuted_pension"},
+			patchOp{Op: "remove", Path: base + "/lump_sum"},
+		)
+	}
+
+	return nil, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
+}
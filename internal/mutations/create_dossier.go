@@ -7,6 +7,7 @@ import (
 	json "github.com/goccy/go-json"
 
 	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
 )
 
 type createDossierProps struct {
@@ -18,7 +19,7 @@ type createDossierProps struct {
 
 type CreateDossierHandler struct{}
 
-func (h *CreateDossierHandler) Execute(state *model.Situation, mutation *model.Mutation) ([]model.CalculationMessage, bool, []byte, []byte) {
+func (h *CreateDossierHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
 	if state.Dossier != nil {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
@@ -51,12 +52,12 @@ func (h *CreateDossierHandler) Execute(state *model.Situation, mutation *model.M
 	// Apply
 	state.Dossier = &model.Dossier{
 		DossierID:      props.DossierID,
-		Status:         "ACTIVE",
+		Status:         model.StatusActive,
 		RetirementDate: nil,
 		Persons: []model.Person{
 			{
 				PersonID:  props.PersonID,
-				Role:      "PARTICIPANT",
+				Role:      roleParticipant,
 				Name:      props.Name,
 				BirthDate: props.BirthDate,
 			},
@@ -0,0 +1,122 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type divorceSplitProps struct {
+	PersonID          string  `json:"person_id"`
+	MarriageStartDate string  `json:"marriage_start_date"`
+	MarriageEndDate   string  `json:"marriage_end_date"`
+	SplitPercentage   float64 `json:"split_percentage"`
+}
+
+// DivorceSplitHandler equalises the pension accrued during a marriage. The
+// partner becomes an EX_PARTNER holding, per policy, split_percentage of the
+// pension accrued between the marriage dates.
+type DivorceSplitHandler struct{}
+
+func (h *DivorceSplitHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props divorceSplitProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	partner := -1
+	for i, p := range state.Dossier.Persons {
+		if p.PersonID == props.PersonID && p.Role == rolePartner {
+			partner = i
+			break
+		}
+	}
+	if partner < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "PARTNER_NOT_FOUND",
+			Message: "No partner with person_id " + props.PersonID + " exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	if props.MarriageEndDate <= props.MarriageStartDate {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_MARRIAGE_PERIOD",
+			Message: "marriage_end_date must be after marriage_start_date",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	if props.SplitPercentage < 0 || props.SplitPercentage > 1 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_SPLIT_PERCENTAGE",
+			Message: "Split percentage must be between 0 and 1",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	marriageStart, _ := fastParseDate(props.MarriageStartDate)
+	marriageEnd, _ := fastParseDate(props.MarriageEndDate)
+
+	// Pension accrued per policy during the marriage, with the same
+	// days/365.25 service and salary history as the retirement calculation.
+	// DC policies accrue capital rather than pension and are not split.
+	policies := state.Dossier.Policies
+	schemes := provider.GetSchemes(uniqueSchemeIDs(policies), schemeregistry.DateRange{From: marriageStart, To: marriageEnd})
+	entitlements := make([]model.PensionEntitlement, 0, len(policies))
+	for i := range policies {
+		p := &policies[i]
+		if schemes[p.SchemeID].IsDC() {
+			continue
+		}
+		empStart, _ := fastParseDate(p.EmploymentStartDate)
+		from := empStart
+		if marriageStart.After(from) {
+			from = marriageStart
+		}
+		sc := schemes[p.SchemeID]
+		accrued := accruedPension(p, sc, salaryPeriods(p, sc), from, employmentEnd(p), marriageEnd)
+		entitlements = append(entitlements, model.PensionEntitlement{
+			PolicyID: p.PolicyID,
+			Amount:   accrued * props.SplitPercentage,
+		})
+	}
+
+	person := &state.Dossier.Persons[partner]
+	base := "/dossier/persons/" + strconv.Itoa(partner)
+	person.Role = roleExPartner
+
+	fwdOps := []patchOp{{Op: "replace", Path: base + "/role", Value: marshalValue(roleExPartner)}}
+	bwdOps := []patchOp{{Op: "replace", Path: base + "/role", Value: marshalValue(rolePartner)}}
+	// pension_entitlements is omitted while empty
+	if len(entitlements) > 0 {
+		person.PensionEntitlements = entitlements
+		fwdOps = append(fwdOps, patchOp{Op: "add", Path: base + "/pension_entitlements", Value: marshalValue(entitlements)})
+		bwdOps = append([]patchOp{{Op: "remove", Path: base + "/pension_entitlements"}}, bwdOps...)
+	}
+
+	return nil, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
+}
+
+// divorceDeductions sums the ex-partner entitlements per policy_id.
+func divorceDeductions(d *model.Dossier) map[string]float64 {
+	var deductions map[string]float64
+	for _, person := range d.Persons {
+		for _, e := range person.PensionEntitlements {
+			if deductions == nil {
+				deductions = make(map[string]float64)
+			}
+			deductions[e.PolicyID] += e.Amount
+		}
+	}
+	return deductions
+}
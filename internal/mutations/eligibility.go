@@ -0,0 +1,71 @@
+package mutations
+
+import (
+	"os"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+// Eligibility modes decide how the rules of the participant's schemes combine
+// into dossier-level retirement eligibility.
+const (
+	// EligibilityStrictest requires the rule of every scheme to be met.
+	EligibilityStrictest = "strictest"
+	// EligibilityLenient requires the rule of at least one scheme to be met.
+	EligibilityLenient = "lenient"
+)
+
+var eligibilityMode = EligibilityStrictest
+
+func init() {
+	SetEligibilityMode(os.Getenv("ELIGIBILITY_MODE"))
+}
+
+// SetEligibilityMode selects how scheme eligibility rules combine. Any value
+// other than EligibilityLenient selects EligibilityStrictest.
+func SetEligibilityMode(mode string) {
+	if mode == EligibilityLenient {
+		eligibilityMode = EligibilityLenient
+		return
+	}
+	eligibilityMode = EligibilityStrictest
+}
+
+// schemeEligible reports whether a participant ageMonths old with totalYears
+// of service may retire under sc: at or after its minimum retirement age, or
+// with at least its minimum years of service.
+func schemeEligible(sc schemeregistry.Scheme, ageMonths int, totalYears float64) bool {
+	return ageMonths >= sc.MinRetirementAge*12 || totalYears >= sc.MinServiceYears
+}
+
+// eligible combines the eligibility of the given schemes per eligibilityMode.
+func eligible(schemes map[string]schemeregistry.Scheme, schemeIDs []string, ageMonths int, totalYears float64) bool {
+	for _, id := range schemeIDs {
+		ok := schemeEligible(schemes[id], ageMonths, totalYears)
+		if eligibilityMode == EligibilityLenient && ok {
+			return true
+		}
+		if eligibilityMode == EligibilityStrictest && !ok {
+			return false
+		}
+	}
+	return eligibilityMode == EligibilityStrictest
+}
+
+// vest zeroes the years and accrued pension of every policy that has not
+// completed its scheme's vesting period, so it takes no part in the
+// distribution, and returns those policies' indices together with the total
+// years of the remaining ones.
+func vest(policies []model.Policy, schemes map[string]schemeregistry.Scheme, years, accrued []float64, totalYears float64) (unvested []int, vestedYears float64) {
+	vestedYears = totalYears
+	for i := range policies {
+		if years[i] >= schemes[policies[i].SchemeID].VestingYears {
+			continue
+		}
+		unvested = append(unvested, i)
+		vestedYears -= years[i]
+		years[i], accrued[i] = 0, 0
+	}
+	return unvested, vestedYears
+}
@@ -1,9 +1,13 @@
 package mutations
 
-import "pension-engine/internal/model"
+import (
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
 
 // MutationHandler defines the contract for all mutation implementations.
 // Execute validates and applies in a single call, returning patches directly.
+// Scheme parameters are read from provider, never from the registry directly.
 type MutationHandler interface {
-	Execute(state *model.Situation, mutation *model.Mutation) (msgs []model.CalculationMessage, hasCritical bool, fwdPatch, bwdPatch []byte)
+	Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) (msgs []model.CalculationMessage, hasCritical bool, fwdPatch, bwdPatch []byte)
 }
@@ -0,0 +1,20 @@
+package mutations
+
+import "pension-engine/internal/model"
+
+const (
+	roleParticipant = "PARTICIPANT"
+	rolePartner     = "PARTNER"
+	roleExPartner   = "EX_PARTNER"
+)
+
+// personIndex returns the index of the first person with the given role, or
+// -1 when the dossier has none.
+func personIndex(d *model.Dossier, role string) int {
+	for i := range d.Persons {
+		if d.Persons[i].Role == role {
+			return i
+		}
+	}
+	return -1
+}
@@ -18,7 +18,7 @@ type projectFutureBenefitsProps struct {
 
 type ProjectFutureBenefitsHandler struct{}
 
-func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation *model.Mutation) ([]model.CalculationMessage, bool, []byte, []byte) {
+func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
 	if state.Dossier == nil {
 		return []model.CalculationMessage{{
 			Level:   model.LevelCritical,
@@ -46,6 +46,18 @@ func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation
 		}}, true, emptyPatch, emptyPatch
 	}
 
+	policies := state.Dossier.Policies
+	n := len(policies)
+
+	// Fetch per-scheme parameters
+	uniqueSchemes := uniqueSchemeIDs(policies)
+	endDate, _ := fastParseDate(props.ProjectionEndDate)
+	schemes := provider.GetSchemes(uniqueSchemes, schemeregistry.DateRange{To: endDate})
+	dc := make([]bool, n)
+	for i := range policies {
+		dc[i] = schemes[policies[i].SchemeID].IsDC()
+	}
+
 	var msgs []model.CalculationMessage
 	for _, p := range state.Dossier.Policies {
 		if props.ProjectionStartDate < p.EmploymentStartDate {
@@ -56,18 +68,19 @@ func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation
 			})
 		}
 	}
+	msgs = append(msgs, salaryCappedWarnings(policies, schemes)...)
 
 	// Apply
 	startDate, _ := fastParseDate(props.ProjectionStartDate)
-	endDate, _ := fastParseDate(props.ProjectionEndDate)
 
-	policies := state.Dossier.Policies
-	n := len(policies)
-
-	// Pre-parse employment start dates
+	// Pre-parse employment dates and salary history
 	empStarts := make([]time.Time, n)
-	for i, p := range policies {
-		empStarts[i], _ = fastParseDate(p.EmploymentStartDate)
+	empEnds := make([]time.Time, n)
+	periods := make([][]salaryPeriod, n)
+	for i := range policies {
+		empStarts[i], _ = fastParseDate(policies[i].EmploymentStartDate)
+		empEnds[i] = employmentEnd(&policies[i])
+		periods[i] = salaryPeriods(&policies[i], schemes[policies[i].SchemeID])
 	}
 
 	// Estimate projection count for pre-allocation
@@ -77,14 +90,19 @@ func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation
 		estCount = months/props.ProjectionIntervalMths + 2
 	}
 
+	// Capture previous projections for backward patches
+	oldProjections := make([]json.RawMessage, n)
+	for i := range policies {
+		oldProjections[i] = marshalValue(policies[i].Projections)
+	}
+
 	// Initialize projections arrays with pre-allocated capacity
 	for i := range state.Dossier.Policies {
 		state.Dossier.Policies[i].Projections = make([]model.Projection, 0, estCount)
 	}
 
-	// Fetch per-scheme accrual rates
-	uniqueSchemes := uniqueSchemeIDs(policies)
-	rates := schemeregistry.GetAccrualRates(uniqueSchemes)
+	// DC capital grows from the mutation's actual_at
+	valuationDate, _ := fastParseDate(mutation.ActualAt)
 
 	// Reuse years slice across iterations
 	years := make([]float64, n)
@@ -94,41 +112,62 @@ func (h *ProjectFutureBenefitsHandler) Execute(state *model.Situation, mutation
 
 		var totalYears float64
 		for i := range policies {
-			y := daysBetween(empStarts[i], projDate) / 365.25
-			if y < 0 {
-				y = 0
+			if dc[i] {
+				continue
 			}
+			y := yearsOfService(empStarts[i], empEnds[i], projDate)
 			years[i] = y
 			totalYears += y
 		}
 
 		var annualPension float64
 		if totalYears > 0 {
-			for i, p := range policies {
-				rate := rates[p.SchemeID]
-				annualPension += (p.Salary * p.PartTimeFactor) * years[i] * rate
+			for i := range policies {
+				if dc[i] {
+					continue
+				}
+				sc := schemes[policies[i].SchemeID]
+				annualPension += accruedPension(&policies[i], sc, periods[i], empStarts[i], empEnds[i], projDate)
 			}
 		}
 
 		for i := range state.Dossier.Policies {
+			p := &state.Dossier.Policies[i]
+			if dc[i] {
+				sc := schemes[p.SchemeID]
+				var capital float64
+				if p.CapitalBalance != nil {
+					capital = projectedCapital(*p.CapitalBalance, sc, daysBetween(valuationDate, projDate)/365.25)
+				}
+				var projected float64
+				if sc.AnnuityFactor > 0 {
+					projected = capital / sc.AnnuityFactor
+				}
+				p.Projections = append(p.Projections, model.Projection{
+					Date:             dateStr,
+					ProjectedPension: projected,
+					ProjectedCapital: &capital,
+				})
+				continue
+			}
 			var projected float64
 			if totalYears > 0 {
 				projected = annualPension * (years[i] / totalYears)
 			}
-			state.Dossier.Policies[i].Projections = append(state.Dossier.Policies[i].Projections, model.Projection{
+			p.Projections = append(p.Projections, model.Projection{
 				Date:             dateStr,
 				ProjectedPension: projected,
 			})
 		}
 	}
 
-	// Generate patches: projections per policy (null → array)
+	// Generate patches: projections per policy (previous value → array)
 	fwdOps := make([]patchOp, n)
 	bwdOps := make([]patchOp, n)
 	for i := range state.Dossier.Policies {
 		path := "/dossier/policies/" + strconv.Itoa(i) + "/projections"
 		fwdOps[i] = patchOp{Op: "replace", Path: path, Value: marshalValue(state.Dossier.Policies[i].Projections)}
-		bwdOps[i] = patchOp{Op: "replace", Path: path, Value: jsonNull}
+		bwdOps[i] = patchOp{Op: "replace", Path: path, Value: oldProjections[i]}
 	}
 
 	return msgs, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
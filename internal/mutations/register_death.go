@@ -0,0 +1,145 @@
+package mutations
+
+import (
+	"strconv"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type registerDeathProps struct {
+	DateOfDeath string `json:"date_of_death"`
+}
+
+// RegisterDeathHandler records the participant's death. The dossier becomes
+// DECEASED, the employments of an active participant end on the date of
+// death, projections are dropped and a partner's survivor entitlement becomes
+// a payable benefit.
+type RegisterDeathHandler struct{}
+
+func (h *RegisterDeathHandler) Execute(state *model.Situation, mutation *model.Mutation, provider schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	participant := personIndex(state.Dossier, roleParticipant)
+	if participant < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "PARTICIPANT_NOT_FOUND",
+			Message: "Dossier has no participant",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props registerDeathProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	person := &state.Dossier.Persons[participant]
+	deathDate, ok := fastParseDate(props.DateOfDeath)
+	if !ok || props.DateOfDeath < person.BirthDate || deathDate.After(time.Now()) {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_DATE_OF_DEATH",
+			Message: "Date of death must be a past date on or after the birth date",
+		}}, true, emptyPatch, emptyPatch
+	}
+	if rd := state.Dossier.RetirementDate; rd != nil && props.DateOfDeath < *rd {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_DATE_OF_DEATH",
+			Message: "Date of death " + props.DateOfDeath + " is before the retirement date " + *rd,
+		}}, true, emptyPatch, emptyPatch
+	}
+	// A retired participant's employment ended at retirement
+	active := state.Dossier.Status == model.StatusActive
+
+	var fwdOps, bwdOps []patchOp
+
+	// Status and date of death
+	personPath := "/dossier/persons/" + strconv.Itoa(participant)
+	fwdOps = append(fwdOps,
+		patchOp{Op: "replace", Path: "/dossier/status", Value: marshalValue(model.StatusDeceased)},
+		patchOp{Op: "add", Path: personPath + "/date_of_death", Value: marshalValue(props.DateOfDeath)},
+	)
+	bwdOps = append(bwdOps,
+		patchOp{Op: "replace", Path: "/dossier/status", Value: marshalValue(state.Dossier.Status)},
+		patchOp{Op: "remove", Path: personPath + "/date_of_death"},
+	)
+	state.Dossier.Status = model.StatusDeceased
+	dateOfDeath := props.DateOfDeath
+	person.DateOfDeath = &dateOfDeath
+
+	// Survivor entitlement per policy. A retired participant's was fixed at
+	// retirement; otherwise it follows from the pension accrued until death.
+	partner := personIndex(state.Dossier, rolePartner)
+	policies := state.Dossier.Policies
+	var accrued []float64
+	var schemes map[string]schemeregistry.Scheme
+	if partner >= 0 && len(policies) > 0 {
+		schemes = provider.GetSchemes(uniqueSchemeIDs(policies), schemeregistry.DateRange{To: deathDate})
+		years, earned, totalYears := accrue(policies, schemes, deathDate)
+		unvested, vestedYears := vest(policies, schemes, years, earned, totalYears)
+		accrued = distribute(policies, schemes, years, earned, vestedYears)
+		for _, i := range unvested {
+			accrued[i] = 0
+		}
+	}
+
+	var survivorBenefit float64
+	for i := range policies {
+		p := &policies[i]
+		base := "/dossier/policies/" + strconv.Itoa(i)
+
+		// Stop accrual
+		if active && (p.EmploymentEndDate == nil || *p.EmploymentEndDate > dateOfDeath) {
+			if p.EmploymentEndDate == nil {
+				bwdOps = append(bwdOps, patchOp{Op: "remove", Path: base + "/employment_end_date"})
+			} else {
+				bwdOps = append(bwdOps, patchOp{Op: "replace", Path: base + "/employment_end_date", Value: marshalValue(*p.EmploymentEndDate)})
+			}
+			fwdOps = append(fwdOps, patchOp{Op: "add", Path: base + "/employment_end_date", Value: marshalValue(dateOfDeath)})
+			end := dateOfDeath
+			p.EmploymentEndDate = &end
+		}
+
+		// Stop projections
+		if p.Projections != nil {
+			fwdOps = append(fwdOps, patchOp{Op: "replace", Path: base + "/projections", Value: jsonNull})
+			bwdOps = append(bwdOps, patchOp{Op: "replace", Path: base + "/projections", Value: marshalValue(p.Projections)})
+			p.Projections = nil
+		}
+
+		if accrued == nil {
+			continue
+		}
+		if p.SurvivorPension == nil {
+			survivor := accrued[i] * schemes[p.SchemeID].SurvivorPercentage
+			p.SurvivorPension = &survivor
+			fwdOps = append(fwdOps, patchOp{Op: "add", Path: base + "/survivor_pension", Value: marshalValue(survivor)})
+			bwdOps = append(bwdOps, patchOp{Op: "remove", Path: base + "/survivor_pension"})
+		}
+		survivorBenefit += *p.SurvivorPension
+	}
+
+	// Payable survivor benefit on the partner
+	if partner >= 0 {
+		path := "/dossier/persons/" + strconv.Itoa(partner) + "/survivor_benefit"
+		state.Dossier.Persons[partner].SurvivorBenefit = &survivorBenefit
+		fwdOps = append(fwdOps, patchOp{Op: "add", Path: path, Value: marshalValue(survivorBenefit)})
+		bwdOps = append(bwdOps, patchOp{Op: "remove", Path: path})
+	}
+
+	// Backward operations undo the forward ones in reverse order
+	for i, j := 0, len(bwdOps)-1; i < j; i, j = i+1, j-1 {
+		bwdOps[i], bwdOps[j] = bwdOps[j], bwdOps[i]
+	}
+
+	return nil, false, marshalPatches(fwdOps), marshalPatches(bwdOps)
+}
@@ -1,14 +1,37 @@
 package mutations
 
+import "sort"
+
 var registry = map[string]MutationHandler{
 	"create_dossier":               &CreateDossierHandler{},
 	"add_policy":                   &AddPolicyHandler{},
 	"apply_indexation":             &ApplyIndexationHandler{},
 	"calculate_retirement_benefit": &CalculateRetirementBenefitHandler{},
 	"project_future_benefits":      &ProjectFutureBenefitsHandler{},
+	"terminate_employment":         &TerminateEmploymentHandler{},
+	"change_salary":                &ChangeSalaryHandler{},
+	"change_part_time_factor":      &ChangePartTimeFactorHandler{},
+	"remove_policy":                &RemovePolicyHandler{},
+	"add_partner":                  &AddPartnerHandler{},
+	"remove_partner":               &RemovePartnerHandler{},
+	"register_death":               &RegisterDeathHandler{},
+	"divorce_split":                &DivorceSplitHandler{},
+	"commute_pension":              &CommutePensionHandler{},
+	"add_contribution":             &AddContributionHandler{},
+	"apply_investment_return":      &ApplyInvestmentReturnHandler{},
 }
 
 func Get(name string) (MutationHandler, bool) {
 	h, ok := registry[name]
 	return h, ok
 }
+
+// Names returns the names of the registered mutations in order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
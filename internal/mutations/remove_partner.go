@@ -0,0 +1,54 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type removePartnerProps struct {
+	PersonID string `json:"person_id"`
+}
+
+type RemovePartnerHandler struct{}
+
+func (h *RemovePartnerHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props removePartnerProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	idx := -1
+	for i, p := range state.Dossier.Persons {
+		if p.PersonID == props.PersonID && p.Role == rolePartner {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "PARTNER_NOT_FOUND",
+			Message: "No partner with person_id " + props.PersonID + " exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	removed := marshalValue(state.Dossier.Persons[idx])
+	persons := state.Dossier.Persons
+	state.Dossier.Persons = append(persons[:idx:idx], persons[idx+1:]...)
+
+	path := "/dossier/persons/" + strconv.Itoa(idx)
+	fwd := marshalPatches([]patchOp{{Op: "remove", Path: path}})
+	bwd := marshalPatches([]patchOp{{Op: "add", Path: path, Value: removed}})
+
+	return nil, false, fwd, bwd
+}
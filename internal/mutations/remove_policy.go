@@ -0,0 +1,60 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type removePolicyProps struct {
+	PolicyID string `json:"policy_id"`
+}
+
+// RemovePolicyHandler deletes a policy. Dossier.PolicySeq is left untouched,
+// so the removed policy_id is never handed out again.
+type RemovePolicyHandler struct{}
+
+func (h *RemovePolicyHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props removePolicyProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	idx := -1
+	for i := range state.Dossier.Policies {
+		if state.Dossier.Policies[i].PolicyID == props.PolicyID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "POLICY_NOT_FOUND",
+			Message: "Policy " + props.PolicyID + " does not exist",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	// Capture the policy for the backward patch before the array shifts
+	removed := marshalValue(state.Dossier.Policies[idx])
+
+	policies := state.Dossier.Policies
+	state.Dossier.Policies = append(policies[:idx:idx], policies[idx+1:]...)
+
+	// Later policies shift down by one; removing and re-adding at the same
+	// index reproduces that in both directions.
+	path := "/dossier/policies/" + strconv.Itoa(idx)
+	fwd := marshalPatches([]patchOp{{Op: "remove", Path: path}})
+	bwd := marshalPatches([]patchOp{{Op: "add", Path: path, Value: removed}})
+
+	return nil, false, fwd, bwd
+}
@@ -0,0 +1,224 @@
+package mutations
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+// salaryPeriod is a parsed SalarySegment.
+type salaryPeriod struct {
+	from      time.Time
+	effective float64 // pensionable salary, see pensionableSalary
+}
+
+// pensionableSalary returns the effective salary that accrues pension under
+// sc: the salary capped at the scheme's maximum pensionable salary, minus the
+// franchise, floored at 0 and multiplied by the part-time factor.
+func pensionableSalary(salary, partTimeFactor float64, sc schemeregistry.Scheme) float64 {
+	if sc.MaxPensionableSalary > 0 && salary > sc.MaxPensionableSalary {
+		salary = sc.MaxPensionableSalary
+	}
+	salary -= sc.Franchise
+	if salary < 0 {
+		return 0
+	}
+	return salary * partTimeFactor
+}
+
+// salaryCapped reports whether the policy's salary, now or in its history,
+// exceeds the scheme's maximum pensionable salary.
+func salaryCapped(p *model.Policy, sc schemeregistry.Scheme) bool {
+	if sc.MaxPensionableSalary <= 0 {
+		return false
+	}
+	if p.Salary > sc.MaxPensionableSalary {
+		return true
+	}
+	for _, seg := range p.SalaryHistory {
+		if seg.Salary > sc.MaxPensionableSalary {
+			return true
+		}
+	}
+	return false
+}
+
+// salaryPeriods parses the policy's salary history. It returns nil when the
+// policy has no history, in which case its current salary applies throughout.
+func salaryPeriods(p *model.Policy, sc schemeregistry.Scheme) []salaryPeriod {
+	if len(p.SalaryHistory) == 0 {
+		return nil
+	}
+	periods := make([]salaryPeriod, len(p.SalaryHistory))
+	for i, seg := range p.SalaryHistory {
+		periods[i].from, _ = fastParseDate(seg.StartDate)
+		periods[i].effective = pensionableSalary(seg.Salary, seg.PartTimeFactor, sc)
+	}
+	return periods
+}
+
+// salaryYears returns Σ(pensionable_salary × years) over the service between
+// start and at, capped at end when the employment has been terminated. Each
+// salary period contributes for the part of the service it covers.
+func salaryYears(p *model.Policy, sc schemeregistry.Scheme, periods []salaryPeriod, start, end, at time.Time) float64 {
+	if periods == nil {
+		return pensionableSalary(p.Salary, p.PartTimeFactor, sc) * yearsOfService(start, end, at)
+	}
+	if !end.IsZero() && end.Before(at) {
+		at = end
+	}
+
+	var total float64
+	for i, period := range periods {
+		from := period.from
+		if from.Before(start) {
+			from = start
+		}
+		to := at
+		if i+1 < len(periods) && periods[i+1].from.Before(to) {
+			to = periods[i+1].from
+		}
+		if to.After(from) {
+			total += period.effective * daysBetween(from, to) / 365.25
+		}
+	}
+	return total
+}
+
+// accruedPension returns Σ(pensionable_salary × years × accrual_rate) over
+// the service between start and at, capped at end. The service is split at
+// the boundaries of the scheme's accrual rate periods so every slice accrues
+// at the rate in effect during it.
+func accruedPension(p *model.Policy, sc schemeregistry.Scheme, periods []salaryPeriod, start, end, at time.Time) float64 {
+	if len(sc.AccrualPeriods) == 0 {
+		return salaryYears(p, sc, periods, start, end, at) * sc.AccrualRate
+	}
+	var total float64
+	from, rate := start, sc.AccrualRate
+	for _, rp := range sc.AccrualPeriods {
+		if rp.From.After(from) {
+			to := rp.From
+			if at.Before(to) {
+				to = at
+			}
+			total += salaryYears(p, sc, periods, from, end, to) * rate
+			from = rp.From
+		}
+		rate = rp.Rate
+	}
+	return total + salaryYears(p, sc, periods, from, end, at)*rate
+}
+
+// changePolicySalary applies a change to the salary segment of policyID that
+// starts at date. The first change creates the history from the policy's
+// current values; a new segment copies the segment in effect at date before
+// update is applied. The policy's Salary and PartTimeFactor follow the
+// latest segment.
+func changePolicySalary(state *model.Situation, policyID, date string, update func(*model.SalarySegment)) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	idx := -1
+	for i := range state.Dossier.Policies {
+		if state.Dossier.Policies[i].PolicyID == policyID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "POLICY_NOT_FOUND",
+			Message: "Policy " + policyID + " does not exist",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	p := &state.Dossier.Policies[idx]
+	if date < p.EmploymentStartDate || (p.EmploymentEndDate != nil && date > *p.EmploymentEndDate) {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_EFFECTIVE_DATE",
+			Message: "Effective date " + date + " is outside the employment period of policy " + policyID,
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	// Capture old state for backward patches
+	oldSalary := p.Salary
+	oldFactor := p.PartTimeFactor
+	oldHistory := p.SalaryHistory
+
+	history := p.SalaryHistory
+	switch {
+	case len(history) == 0:
+		history = []model.SalarySegment{{StartDate: p.EmploymentStartDate, Salary: p.Salary, PartTimeFactor: p.PartTimeFactor}}
+	case history[0].StartDate > p.EmploymentStartDate:
+		// A supplied history may start late; its first values then cover
+		// the employment from its start
+		first := history[0]
+		first.StartDate = p.EmploymentStartDate
+		history = append(append(make([]model.SalarySegment, 0, len(history)+2), first), history...)
+	default:
+		history = append(make([]model.SalarySegment, 0, len(history)+1), history...)
+	}
+
+	// Segments are ordered by StartDate and the first starts at employment
+	// start, so pos > 0 whenever date is not an existing segment start.
+	pos := sort.Search(len(history), func(i int) bool { return history[i].StartDate >= date })
+	if pos == len(history) || history[pos].StartDate != date {
+		seg := history[pos-1]
+		seg.StartDate = date
+		history = append(history, model.SalarySegment{})
+		copy(history[pos+1:], history[pos:])
+		history[pos] = seg
+	}
+	update(&history[pos])
+
+	last := history[len(history)-1]
+	p.SalaryHistory = history
+	p.Salary = last.Salary
+	p.PartTimeFactor = last.PartTimeFactor
+
+	base := "/dossier/policies/" + strconv.Itoa(idx)
+	historyOp := "replace"
+	var oldHistoryOp patchOp
+	if len(oldHistory) == 0 {
+		historyOp = "add"
+		oldHistoryOp = patchOp{Op: "remove", Path: base + "/salary_history"}
+	} else {
+		oldHistoryOp = patchOp{Op: "replace", Path: base + "/salary_history", Value: marshalValue(oldHistory)}
+	}
+
+	fwd := marshalPatches([]patchOp{
+		{Op: "replace", Path: base + "/salary", Value: marshalValue(p.Salary)},
+		{Op: "replace", Path: base + "/part_time_factor", Value: marshalValue(p.PartTimeFactor)},
+		{Op: historyOp, Path: base + "/salary_history", Value: marshalValue(history)},
+	})
+	bwd := marshalPatches([]patchOp{
+		{Op: "replace", Path: base + "/salary", Value: marshalValue(oldSalary)},
+		{Op: "replace", Path: base + "/part_time_factor", Value: marshalValue(oldFactor)},
+		oldHistoryOp,
+	})
+	return nil, false, fwd, bwd
+}
+
+// indexedHistory returns a copy of history with every salary multiplied by
+// factor and clamped at 0.
+func indexedHistory(history []model.SalarySegment, factor float64) []model.SalarySegment {
+	out := make([]model.SalarySegment, len(history))
+	for i, seg := range history {
+		seg.Salary *= factor
+		if seg.Salary < 0 {
+			seg.Salary = 0
+		}
+		out[i] = seg
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package mutations
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+type terminateEmploymentProps struct {
+	PolicyID          string `json:"policy_id"`
+	EmploymentEndDate string `json:"employment_end_date"`
+}
+
+type TerminateEmploymentHandler struct{}
+
+func (h *TerminateEmploymentHandler) Execute(state *model.Situation, mutation *model.Mutation, _ schemeregistry.SchemeProvider) ([]model.CalculationMessage, bool, []byte, []byte) {
+	if state.Dossier == nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "DOSSIER_NOT_FOUND",
+			Message: "No dossier exists",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	var props terminateEmploymentProps
+	json.Unmarshal(mutation.MutationProperties, &props)
+
+	idx := -1
+	for i := range state.Dossier.Policies {
+		if state.Dossier.Policies[i].PolicyID == props.PolicyID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "POLICY_NOT_FOUND",
+			Message: "Policy " + props.PolicyID + " does not exist",
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	policy := &state.Dossier.Policies[idx]
+	if policy.EmploymentEndDate != nil {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "EMPLOYMENT_ALREADY_TERMINATED",
+			Message: "Employment for policy " + props.PolicyID + " already ended on " + *policy.EmploymentEndDate,
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	if props.EmploymentEndDate < policy.EmploymentStartDate {
+		return []model.CalculationMessage{{
+			Level:   model.LevelCritical,
+			Code:    "INVALID_EMPLOYMENT_END_DATE",
+			Message: "Employment end date " + props.EmploymentEndDate + " is before employment start date " + policy.EmploymentStartDate + " for policy " + props.PolicyID,
+		}}, true, emptyPatch, emptyPatch
+	}
+
+	// Apply
+	endDate := props.EmploymentEndDate
+	policy.EmploymentEndDate = &endDate
+
+	path := "/dossier/policies/" + strconv.Itoa(idx) + "/employment_end_date"
+	fwd := marshalPatches([]patchOp{{Op: "add", Path: path, Value: marshalValue(endDate)}})
+	bwd := marshalPatches([]patchOp{{Op: "remove", Path: path}})
+
+	return nil, false, fwd, bwd
+}
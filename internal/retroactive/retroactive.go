@@ -0,0 +1,332 @@
+// Package retroactive inserts or cancels a mutation in the past of a
+// mutation sequence, recalculates the sequence and reports the impact on
+// every later mutation.
+package retroactive
+
+import (
+	"errors"
+	"math"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/jsonpatch"
+	"pension-engine/internal/model"
+)
+
+var (
+	ErrNoChange         = errors.New("exactly one of insert or cancel_mutation_id is required")
+	ErrMutationNotFound = errors.New("mutation to cancel not found")
+	ErrDuplicateID      = errors.New("inserted mutation_id already exists in the sequence")
+	// ErrShiftsPolicyIDs is returned when inserting or cancelling an
+	// add_policy would renumber the policies added after it, so later
+	// mutations and the impact report would address different policies.
+	ErrShiftsPolicyIDs = errors.New("inserting or cancelling add_policy before another add_policy would renumber policies")
+	// ErrEmptySequence is returned when cancelling the only mutation would
+	// leave nothing to recalculate.
+	ErrEmptySequence = errors.New("cancelling the only mutation would leave an empty sequence")
+)
+
+// Change describes a back-dated correction: either a mutation to insert at
+// its actual_at, or the mutation_id of a mutation to cancel.
+type Change struct {
+	Insert           *model.Mutation `json:"insert,omitempty"`
+	CancelMutationID string          `json:"cancel_mutation_id,omitempty"`
+}
+
+// Result compares the original and the recalculated sequence.
+type Result struct {
+	// ChangeIndex is the position in the original sequence from which
+	// results can differ: where the mutation was inserted or cancelled.
+	ChangeIndex      int                        `json:"change_index"`
+	Impacts          []MutationImpact           `json:"impacts"`
+	EndSituationDiff []jsonpatch.Operation      `json:"end_situation_diff"`
+	Original         *model.CalculationResponse `json:"original"`
+	Recalculated     *model.CalculationResponse `json:"recalculated"`
+
+	// Mutations is the corrected sequence that produced Recalculated.
+	Mutations []model.Mutation `json:"-"`
+}
+
+// MutationImpact lists what changed for one mutation that follows the
+// change point. Indexes are -1 when the mutation was not applied in that
+// run, e.g. because an earlier mutation now fails.
+type MutationImpact struct {
+	MutationID               string                     `json:"mutation_id"`
+	OriginalIndex            int                        `json:"original_index"`
+	RecalculatedIndex        int                        `json:"recalculated_index"`
+	AttainablePensionChanged bool                       `json:"attainable_pension_changed"`
+	ProjectionsChanged       bool                       `json:"projections_changed"`
+	MessagesChanged          bool                       `json:"messages_changed"`
+	Policies                 []PolicyImpact             `json:"policies,omitempty"`
+	OriginalMessages         []model.CalculationMessage `json:"original_messages,omitempty"`
+	RecalculatedMessages     []model.CalculationMessage `json:"recalculated_messages,omitempty"`
+}
+
+// PolicyImpact holds the before and after values of one policy.
+type PolicyImpact struct {
+	PolicyID                      string             `json:"policy_id"`
+	OriginalAttainablePension     *float64           `json:"original_attainable_pension"`
+	RecalculatedAttainablePension *float64           `json:"recalculated_attainable_pension"`
+	OriginalProjections           []model.Projection `json:"original_projections,omitempty"`
+	RecalculatedProjections       []model.Projection `json:"recalculated_projections,omitempty"`
+}
+
+// Apply applies change to the mutations of req, recalculates both sequences
+// with engine.Process and compares every mutation after the change point.
+func Apply(req *model.CalculationRequest, change Change) (*Result, error) {
+	muts, at, err := rewrite(req.CalculationInstructions.Mutations, change)
+	if err != nil {
+		return nil, err
+	}
+
+	corrected := *req
+	corrected.CalculationInstructions.Mutations = muts
+
+	original := engine.Process(req)
+	recalculated := engine.Process(&corrected)
+
+	impacts, err := compare(&original.CalculationResult, &recalculated.CalculationResult, at)
+	if err != nil {
+		return nil, err
+	}
+
+	oldEnd, _ := json.Marshal(original.CalculationResult.EndSituation.Situation)
+	newEnd, _ := json.Marshal(recalculated.CalculationResult.EndSituation.Situation)
+	endDiff, err := jsonpatch.Diff(oldEnd, newEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ChangeIndex:      at,
+		Impacts:          impacts,
+		EndSituationDiff: endDiff,
+		Original:         original,
+		Recalculated:     recalculated,
+		Mutations:        muts,
+	}, nil
+}
+
+// rewrite returns a new sequence with the change applied and the index at
+// which it takes effect. Inserted mutations go after every mutation with
+// the same or an earlier actual_at.
+func rewrite(muts []model.Mutation, change Change) ([]model.Mutation, int, error) {
+	if (change.Insert == nil) == (change.CancelMutationID == "") {
+		return nil, 0, ErrNoChange
+	}
+
+	if change.Insert != nil {
+		at := len(muts)
+		for i, m := range muts {
+			if m.MutationID == change.Insert.MutationID {
+				return nil, 0, ErrDuplicateID
+			}
+			if at == len(muts) && m.ActualAt > change.Insert.ActualAt {
+				at = i
+			}
+		}
+		if change.Insert.MutationDefinitionName == addPolicy && addsPolicy(muts[at:]) {
+			return nil, 0, ErrShiftsPolicyIDs
+		}
+		out := make([]model.Mutation, 0, len(muts)+1)
+		out = append(out, muts[:at]...)
+		out = append(out, *change.Insert)
+		out = append(out, muts[at:]...)
+		return out, at, nil
+	}
+
+	for i, m := range muts {
+		if m.MutationID == change.CancelMutationID {
+			if m.MutationDefinitionName == addPolicy && addsPolicy(muts[i+1:]) {
+				return nil, 0, ErrShiftsPolicyIDs
+			}
+			if len(muts) == 1 {
+				return nil, 0, ErrEmptySequence
+			}
+			out := make([]model.Mutation, 0, len(muts)-1)
+			out = append(out, muts[:i]...)
+			out = append(out, muts[i+1:]...)
+			return out, i, nil
+		}
+	}
+	return nil, 0, ErrMutationNotFound
+}
+
+const addPolicy = "add_policy"
+
+// addsPolicy reports whether muts add a policy. Policy IDs are numbered in
+// the order of add_policy, so one added or removed before them renumbers
+// these.
+func addsPolicy(muts []model.Mutation) bool {
+	for _, m := range muts {
+		if m.MutationDefinitionName == addPolicy {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(original, recalculated *model.CalculationResult, at int) ([]MutationImpact, error) {
+	oldSits, err := engine.Situations(original)
+	if err != nil {
+		return nil, err
+	}
+	newSits, err := engine.Situations(recalculated)
+	if err != nil {
+		return nil, err
+	}
+
+	newIndex := make(map[string]int, len(recalculated.Mutations))
+	for i, pm := range recalculated.Mutations {
+		newIndex[pm.Mutation.MutationID] = i
+	}
+
+	impacts := []MutationImpact{}
+	for i := at; i < len(original.Mutations); i++ {
+		id := original.Mutations[i].Mutation.MutationID
+		j, processed := newIndex[id]
+		if !processed {
+			j = -1
+		}
+
+		imp := MutationImpact{MutationID: id, OriginalIndex: i, RecalculatedIndex: j}
+		var oldSit, newSit *model.Situation
+		if i < len(oldSits) {
+			oldSit = &oldSits[i]
+		} else {
+			imp.OriginalIndex = -1
+		}
+		if j >= 0 && j < len(newSits) {
+			newSit = &newSits[j]
+		} else {
+			imp.RecalculatedIndex = -1
+		}
+
+		imp.OriginalMessages = messagesOf(original, i)
+		if j >= 0 {
+			imp.RecalculatedMessages = messagesOf(recalculated, j)
+		}
+		imp.MessagesChanged = !sameMessages(imp.OriginalMessages, imp.RecalculatedMessages)
+		comparePolicies(&imp, oldSit, newSit)
+
+		appliedChanged := (imp.OriginalIndex < 0) != (imp.RecalculatedIndex < 0)
+		if appliedChanged || imp.AttainablePensionChanged || imp.ProjectionsChanged || imp.MessagesChanged {
+			impacts = append(impacts, imp)
+		}
+	}
+	return impacts, nil
+}
+
+func comparePolicies(imp *MutationImpact, oldSit, newSit *model.Situation) {
+	oldPolicies := policiesByID(oldSit)
+	newPolicies := policiesByID(newSit)
+
+	ids := make([]string, 0, len(oldPolicies)+len(newPolicies))
+	for _, p := range policiesOf(oldSit) {
+		ids = append(ids, p.PolicyID)
+	}
+	for _, p := range policiesOf(newSit) {
+		if _, ok := oldPolicies[p.PolicyID]; !ok {
+			ids = append(ids, p.PolicyID)
+		}
+	}
+
+	for _, id := range ids {
+		o, n := oldPolicies[id], newPolicies[id]
+		var pi PolicyImpact
+		pi.PolicyID = id
+		changed := false
+		if o != nil {
+			pi.OriginalAttainablePension = o.AttainablePension
+		}
+		if n != nil {
+			pi.RecalculatedAttainablePension = n.AttainablePension
+		}
+		if !sameAmount(pi.OriginalAttainablePension, pi.RecalculatedAttainablePension) {
+			imp.AttainablePensionChanged = true
+			changed = true
+		}
+		var op, np []model.Projection
+		if o != nil {
+			op = o.Projections
+		}
+		if n != nil {
+			np = n.Projections
+		}
+		if !sameProjections(op, np) {
+			imp.ProjectionsChanged = true
+			pi.OriginalProjections, pi.RecalculatedProjections = op, np
+			changed = true
+		}
+		if changed {
+			imp.Policies = append(imp.Policies, pi)
+		}
+	}
+}
+
+func policiesOf(s *model.Situation) []model.Policy {
+	if s == nil || s.Dossier == nil {
+		return nil
+	}
+	return s.Dossier.Policies
+}
+
+func policiesByID(s *model.Situation) map[string]*model.Policy {
+	policies := policiesOf(s)
+	m := make(map[string]*model.Policy, len(policies))
+	for i := range policies {
+		m[policies[i].PolicyID] = &policies[i]
+	}
+	return m
+}
+
+func messagesOf(result *model.CalculationResult, i int) []model.CalculationMessage {
+	if i < 0 || i >= len(result.Mutations) {
+		return nil
+	}
+	idx := result.Mutations[i].CalculationMessageIndexes
+	if len(idx) == 0 {
+		return nil
+	}
+	msgs := make([]model.CalculationMessage, 0, len(idx))
+	for _, id := range idx {
+		msgs = append(msgs, result.Messages[id])
+	}
+	return msgs
+}
+
+// sameMessages compares level, code and text; message IDs shift with the
+// sequence and are ignored.
+func sameMessages(a, b []model.CalculationMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Level != b[i].Level || a[i].Code != b[i].Code || a[i].Message != b[i].Message {
+			return false
+		}
+	}
+	return true
+}
+
+const amountEpsilon = 1e-9
+
+func sameAmount(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Abs(*a-*b) <= amountEpsilon
+}
+
+func sameProjections(a, b []model.Projection) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for i := range a {
+		if a[i].Date != b[i].Date || math.Abs(a[i].ProjectedPension-b[i].ProjectedPension) > amountEpsilon {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package retroactive
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"pension-engine/internal/model"
+)
+
+const dossierID = "d2222222-2222-2222-2222-222222222222"
+
+func TestInsertSalaryCorrectionBeforeRetirement(t *testing.T) {
+	req := makeReq(
+		mut("m1", "create_dossier", "2020-01-01", `{"dossier_id":"`+dossierID+`","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`),
+		mut("m2", "add_policy", "2020-01-01", `{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}`),
+		mut("m3", "calculate_retirement_benefit", "2025-07-01", `{"retirement_date":"2025-07-01"}`),
+	)
+	correction := mut("m9", "apply_indexation", "2024-01-01", `{"percentage":0.1}`)
+
+	res, err := Apply(req, Change{Insert: &correction})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ChangeIndex != 2 {
+		t.Fatalf("expected change_index 2, got %d", res.ChangeIndex)
+	}
+	if len(res.Impacts) != 1 || res.Impacts[0].MutationID != "m3" {
+		t.Fatalf("expected only m3 to be impacted, got %+v", res.Impacts)
+	}
+	imp := res.Impacts[0]
+	if !imp.AttainablePensionChanged || imp.OriginalIndex != 2 || imp.RecalculatedIndex != 3 {
+		t.Fatalf("unexpected impact: %+v", imp)
+	}
+	p := imp.Policies[0]
+	if *p.RecalculatedAttainablePension <= *p.OriginalAttainablePension {
+		t.Fatalf("expected higher pension after salary correction, got %v -> %v", *p.OriginalAttainablePension, *p.RecalculatedAttainablePension)
+	}
+
+	paths := map[string]bool{}
+	for _, op := range res.EndSituationDiff {
+		paths[op.Path] = true
+	}
+	if !paths["/dossier/policies/0/salary"] || !paths["/dossier/policies/0/attainable_pension"] {
+		t.Fatalf("expected salary and pension in end situation diff, got %+v", res.EndSituationDiff)
+	}
+}
+
+func TestCancelReportsMutationsThatNoLongerApply(t *testing.T) {
+	req := makeReq(
+		mut("m1", "create_dossier", "2020-01-01", `{"dossier_id":"`+dossierID+`","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`),
+		mut("m2", "add_policy", "2020-01-01", `{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}`),
+		mut("m3", "calculate_retirement_benefit", "2025-07-01", `{"retirement_date":"2025-07-01"}`),
+	)
+
+	res, err := Apply(req, Change{CancelMutationID: "m2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Recalculated.CalculationMetadata.CalculationOutcome != model.OutcomeFailure {
+		t.Fatal("expected retirement without policies to fail")
+	}
+	if len(res.Impacts) != 2 {
+		t.Fatalf("expected impacts for m2 and m3, got %+v", res.Impacts)
+	}
+	if res.Impacts[0].MutationID != "m2" || res.Impacts[0].RecalculatedIndex != -1 {
+		t.Fatalf("expected cancelled m2 to be reported as not applied, got %+v", res.Impacts[0])
+	}
+	if m3 := res.Impacts[1]; !m3.MessagesChanged || m3.RecalculatedIndex != -1 || m3.RecalculatedMessages[0].Code != "NO_POLICIES" {
+		t.Fatalf("expected m3 to fail with NO_POLICIES, got %+v", m3)
+	}
+}
+
+func TestApplyRejectsInvalidChanges(t *testing.T) {
+	req := makeReq(mut("m1", "create_dossier", "2020-01-01", `{}`))
+	dup := mut("m1", "apply_indexation", "2021-01-01", `{"percentage":0.1}`)
+
+	cases := []struct {
+		change Change
+		want   error
+	}{
+		{Change{}, ErrNoChange},
+		{Change{CancelMutationID: "missing"}, ErrMutationNotFound},
+		{Change{Insert: &dup}, ErrDuplicateID},
+	}
+	for _, tc := range cases {
+		if _, err := Apply(req, tc.change); !errors.Is(err, tc.want) {
+			t.Fatalf("expected %v, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestApplyRejectsEmptySequence(t *testing.T) {
+	req := makeReq(mut("m1", "create_dossier", "2020-01-01", `{"dossier_id":"`+dossierID+`","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`))
+	if _, err := Apply(req, Change{CancelMutationID: "m1"}); !errors.Is(err, ErrEmptySequence) {
+		t.Fatalf("expected ErrEmptySequence, got %v", err)
+	}
+}
+
+func TestApplyRejectsPolicyRenumbering(t *testing.T) {
+	create := mut("m1", "create_dossier", "2020-01-01", `{"dossier_id":"`+dossierID+`","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`)
+	policy := func(id, actualAt string) model.Mutation {
+		return mut(id, "add_policy", actualAt, `{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}`)
+	}
+	req := makeReq(
+		create,
+		policy("m2", "2020-01-01"),
+		policy("m3", "2021-01-01"),
+		mut("m4", "change_salary", "2022-01-01", `{"policy_id":"`+dossierID+`-2","effective_date":"2022-01-01","salary":60000}`),
+	)
+
+	// Cancelling m2 would turn m3's policy -2 into -1
+	if _, err := Apply(req, Change{CancelMutationID: "m2"}); !errors.Is(err, ErrShiftsPolicyIDs) {
+		t.Fatalf("cancel m2: expected ErrShiftsPolicyIDs, got %v", err)
+	}
+	early := policy("m5", "2020-06-01")
+	if _, err := Apply(req, Change{Insert: &early}); !errors.Is(err, ErrShiftsPolicyIDs) {
+		t.Fatalf("insert before m3: expected ErrShiftsPolicyIDs, got %v", err)
+	}
+
+	// The last add_policy may go, and one may be added after all others
+	if _, err := Apply(req, Change{CancelMutationID: "m3"}); err != nil {
+		t.Fatalf("cancel m3: %v", err)
+	}
+	late := policy("m6", "2023-01-01")
+	if _, err := Apply(req, Change{Insert: &late}); err != nil {
+		t.Fatalf("insert after m3: %v", err)
+	}
+}
+
+func makeReq(muts ...model.Mutation) *model.CalculationRequest {
+	return &model.CalculationRequest{
+		TenantID:                "test",
+		CalculationInstructions: model.CalculationInstructions{Mutations: muts},
+	}
+}
+
+func mut(id, name, actualAt, props string) model.Mutation {
+	return model.Mutation{
+		MutationID:             id,
+		MutationDefinitionName: name,
+		MutationType:           "DOSSIER",
+		ActualAt:               actualAt,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
@@ -0,0 +1,137 @@
+// Package scenario runs what-if variants of a dossier: a shared mutation
+// prefix is calculated once and each alternative suffix is calculated on
+// its own copy of the resulting situation.
+package scenario
+
+import (
+	"errors"
+	"sync"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+)
+
+var (
+	ErrNoPrefix    = errors.New("at least one prefix mutation is required")
+	ErrNoScenarios = errors.New("at least one scenario is required")
+	ErrEmptyBranch = errors.New("every scenario needs a scenario_id and at least one mutation")
+)
+
+// Request is a shared prefix plus N alternative suffixes.
+type Request struct {
+	TenantID  string           `json:"tenant_id"`
+	Prefix    []model.Mutation `json:"prefix"`
+	Scenarios []Branch         `json:"scenarios"`
+}
+
+// Branch is one alternative suffix.
+type Branch struct {
+	ScenarioID string           `json:"scenario_id"`
+	Mutations  []model.Mutation `json:"mutations"`
+}
+
+// Response holds the comparison table next to every full calculation.
+type Response struct {
+	Prefix     *model.CalculationResponse `json:"prefix"`
+	Comparison []ComparisonRow            `json:"comparison"`
+	Scenarios  []Result                   `json:"scenarios"`
+}
+
+// ComparisonRow is one policy in one scenario.
+type ComparisonRow struct {
+	ScenarioID        string             `json:"scenario_id"`
+	Outcome           string             `json:"calculation_outcome"`
+	PolicyID          string             `json:"policy_id"`
+	SchemeID          string             `json:"scheme_id"`
+	AttainablePension *float64           `json:"attainable_pension"`
+	Projections       []model.Projection `json:"projections"`
+}
+
+// Result is the full calculation of one scenario on top of the prefix.
+type Result struct {
+	ScenarioID string                     `json:"scenario_id"`
+	Response   *model.CalculationResponse `json:"calculation_response"`
+}
+
+// Run calculates the prefix once and, if it succeeds, every branch
+// concurrently. When the prefix fails no branches are calculated.
+func Run(req *Request) (*Response, error) {
+	if len(req.Prefix) == 0 {
+		return nil, ErrNoPrefix
+	}
+	if len(req.Scenarios) == 0 {
+		return nil, ErrNoScenarios
+	}
+	for _, b := range req.Scenarios {
+		if b.ScenarioID == "" || len(b.Mutations) == 0 {
+			return nil, ErrEmptyBranch
+		}
+	}
+
+	prefix := engine.Process(&model.CalculationRequest{
+		TenantID:                req.TenantID,
+		CalculationInstructions: model.CalculationInstructions{Mutations: req.Prefix},
+	})
+	resp := &Response{Prefix: prefix, Comparison: []ComparisonRow{}, Scenarios: []Result{}}
+	if prefix.CalculationMetadata.CalculationOutcome != model.OutcomeSuccess {
+		return resp, nil
+	}
+
+	end := prefix.CalculationResult.EndSituation
+	seq := 0
+	if end.Situation.Dossier != nil {
+		seq = end.Situation.Dossier.PolicySeq
+	}
+
+	// Process copies its initial situation, so every branch works on its
+	// own clone of the prefix end situation.
+	resp.Scenarios = make([]Result, len(req.Scenarios))
+	var wg sync.WaitGroup
+	for i := range req.Scenarios {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := &req.Scenarios[i]
+			resp.Scenarios[i] = Result{
+				ScenarioID: b.ScenarioID,
+				Response: engine.Process(&model.CalculationRequest{
+					TenantID: req.TenantID,
+					CalculationInstructions: model.CalculationInstructions{
+						Mutations: b.Mutations,
+						InitialSituation: &model.InitialSituation{
+							ActualAt:  end.ActualAt,
+							Situation: end.Situation,
+							PolicySeq: seq,
+						},
+					},
+				}),
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, r := range resp.Scenarios {
+		resp.Comparison = append(resp.Comparison, rows(r)...)
+	}
+	return resp, nil
+}
+
+func rows(r Result) []ComparisonRow {
+	outcome := r.Response.CalculationMetadata.CalculationOutcome
+	d := r.Response.CalculationResult.EndSituation.Situation.Dossier
+	if d == nil {
+		return nil
+	}
+	out := make([]ComparisonRow, len(d.Policies))
+	for i, p := range d.Policies {
+		out[i] = ComparisonRow{
+			ScenarioID:        r.ScenarioID,
+			Outcome:           outcome,
+			PolicyID:          p.PolicyID,
+			SchemeID:          p.SchemeID,
+			AttainablePension: p.AttainablePension,
+			Projections:       p.Projections,
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package scenario
+
+import (
+	"encoding/json"
+	"testing"
+
+	"pension-engine/internal/model"
+)
+
+const dossierID = "d2222222-2222-2222-2222-222222222222"
+
+func TestRunComparesRetirementDates(t *testing.T) {
+	req := &Request{
+		TenantID: "test",
+		Prefix: []model.Mutation{
+			mut("m1", "create_dossier", "2020-01-01", `{"dossier_id":"`+dossierID+`","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`),
+			mut("m2", "add_policy", "2020-01-01", `{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}`),
+		},
+		Scenarios: []Branch{
+			{ScenarioID: "retire-65", Mutations: []model.Mutation{retire("2025-06-15")}},
+			{ScenarioID: "retire-67", Mutations: []model.Mutation{retire("2027-06-15")}},
+			{ScenarioID: "extra-policy", Mutations: []model.Mutation{
+				mut("m3", "add_policy", "2021-01-01", `{"scheme_id":"SCHEME-B","employment_start_date":"2010-01-01","salary":60000,"part_time_factor":0.5}`),
+			}},
+		},
+	}
+
+	resp, err := Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Scenarios) != 3 || resp.Scenarios[1].ScenarioID != "retire-67" {
+		t.Fatalf("expected scenarios in request order, got %+v", resp.Scenarios)
+	}
+	if len(resp.Comparison) != 4 {
+		t.Fatalf("expected 4 comparison rows, got %d", len(resp.Comparison))
+	}
+
+	at65 := *resp.Comparison[0].AttainablePension
+	at67 := *resp.Comparison[1].AttainablePension
+	if at67 <= at65 {
+		t.Fatalf("expected later retirement to yield more: %.2f vs %.2f", at65, at67)
+	}
+
+	extra := resp.Scenarios[2].Response.CalculationResult.EndSituation.Situation.Dossier
+	if extra.Policies[1].PolicyID != dossierID+"-2" {
+		t.Fatalf("expected branch policy numbering to continue from prefix, got %s", extra.Policies[1].PolicyID)
+	}
+	if n := len(resp.Prefix.CalculationResult.EndSituation.Situation.Dossier.Policies); n != 1 {
+		t.Fatalf("branches must not change the prefix situation, got %d policies", n)
+	}
+}
+
+func TestRunSkipsBranchesWhenPrefixFails(t *testing.T) {
+	resp, err := Run(&Request{
+		TenantID:  "test",
+		Prefix:    []model.Mutation{retire("2025-01-01")},
+		Scenarios: []Branch{{ScenarioID: "a", Mutations: []model.Mutation{retire("2026-01-01")}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Prefix.CalculationMetadata.CalculationOutcome != model.OutcomeFailure || len(resp.Scenarios) != 0 {
+		t.Fatal("expected failed prefix and no scenario results")
+	}
+}
+
+func retire(date string) model.Mutation {
+	return mut("r-"+date, "calculate_retirement_benefit", date, `{"retirement_date":"`+date+`"}`)
+}
+
+func mut(id, name, actualAt, props string) model.Mutation {
+	return model.Mutation{
+		MutationID:             id,
+		MutationDefinitionName: name,
+		MutationType:           "DOSSIER",
+		ActualAt:               actualAt,
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(props),
+	}
+}
@@ -0,0 +1,286 @@
+// Package schema validates mutation_properties against the JSON schemas
+// declared in mutation-definitions/. Only the keywords those documents use
+// are supported.
+package schema
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	definitions "pension-engine/mutation-definitions"
+)
+
+// Schema is the subset of JSON Schema (draft 2019-09) used by the
+// mutation definitions.
+type Schema struct {
+	Type                 typeList           `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Format               string             `json:"format"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+	MinLength            *int               `json:"minLength"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+}
+
+// typeList accepts both `"type": "string"` and `"type": ["string", "null"]`.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*t = typeList{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*t = l
+	return nil
+}
+
+type definition struct {
+	Name       string  `json:"name"`
+	JSONSchema *Schema `json:"json_schema"`
+}
+
+// Violation is a single schema violation. Pointer is the RFC 6901 JSON
+// pointer of the offending value within mutation_properties.
+type Violation struct {
+	Pointer string
+	Message string
+}
+
+// LoadError reports a mutation definition file that could not be loaded.
+type LoadError struct {
+	File string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+var errMissingSchema = errors.New("definition has no name or json_schema")
+
+var schemas map[string]*Schema
+
+func init() {
+	loaded, err := Load(definitions.FS)
+	if err != nil {
+		panic("schema: " + err.Error())
+	}
+	schemas = loaded
+}
+
+// Load reads every *.json mutation definition in fsys and returns the
+// schemas keyed by mutation definition name.
+func Load(fsys fs.FS) (map[string]*Schema, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*Schema, len(files))
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		var def definition
+		if err := json.Unmarshal(b, &def); err != nil {
+			return nil, &LoadError{File: f, Err: err}
+		}
+		if def.Name == "" || def.JSONSchema == nil {
+			return nil, &LoadError{File: f, Err: errMissingSchema}
+		}
+		result[def.Name] = def.JSONSchema
+	}
+	return result, nil
+}
+
+// Has reports whether the named mutation definition declares a schema.
+func Has(name string) bool {
+	_, ok := schemas[name]
+	return ok
+}
+
+// Validate checks raw mutation properties against the schema of the named
+// mutation definition. Mutations without a definition are not checked.
+func Validate(name string, raw []byte) []Violation {
+	s, ok := schemas[name]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return []Violation{{Message: "is not valid JSON"}}
+		}
+	}
+	var out []Violation
+	s.validate(v, "", &out)
+	return out
+}
+
+func (s *Schema) validate(v interface{}, ptr string, out *[]Violation) {
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		*out = append(*out, Violation{ptr, "must be of type " + strings.Join(s.Type, " or ")})
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		*out = append(*out, Violation{ptr, "must be one of the allowed values"})
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(val, ptr, out)
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			*out = append(*out, Violation{ptr, "must contain at least " + strconv.Itoa(*s.MinItems) + " items"})
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(item, ptr+"/"+strconv.Itoa(i), out)
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(val)) < *s.MinLength {
+			*out = append(*out, Violation{ptr, "must be at least " + strconv.Itoa(*s.MinLength) + " characters"})
+		}
+		if s.Format != "" && !validFormat(s.Format, val) {
+			*out = append(*out, Violation{ptr, "must be a valid " + s.Format})
+		}
+	case float64:
+		s.validateNumber(val, ptr, out)
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, ptr string, out *[]Violation) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*out = append(*out, Violation{ptr + "/" + escape(name), "is required"})
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		prop, known := s.Properties[k]
+		if !known {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*out = append(*out, Violation{ptr + "/" + escape(k), "is not an allowed property"})
+			}
+			continue
+		}
+		prop.validate(obj[k], ptr+"/"+escape(k), out)
+	}
+}
+
+func (s *Schema) validateNumber(n float64, ptr string, out *[]Violation) {
+	if s.Minimum != nil && n < *s.Minimum {
+		*out = append(*out, Violation{ptr, "must be >= " + formatNumber(*s.Minimum)})
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		*out = append(*out, Violation{ptr, "must be <= " + formatNumber(*s.Maximum)})
+	}
+	if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+		*out = append(*out, Violation{ptr, "must be > " + formatNumber(*s.ExclusiveMinimum)})
+	}
+	if s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum {
+		*out = append(*out, Violation{ptr, "must be < " + formatNumber(*s.ExclusiveMaximum)})
+	}
+}
+
+func (t typeList) matches(v interface{}) bool {
+	for _, name := range t {
+		switch name {
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := v.(float64); ok && n == math.Trunc(n) {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// validFormat asserts the formats the calculations depend on. Draft 2019-09
+// treats format as an annotation, so others (e.g. uuid identifiers) are
+// accepted as-is.
+func validFormat(format, s string) bool {
+	switch format {
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}
+	return true
+}
+
+// escape encodes a property name as a JSON pointer reference token.
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
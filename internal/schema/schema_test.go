@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	json "github.com/goccy/go-json"
+)
+
+const testDefinition = `{
+  "name": "test_mutation",
+  "json_schema": {
+    "type": "object",
+    "properties": {
+      "start_date": {"type": "string", "format": "date"},
+      "amount": {"type": "number", "minimum": 0, "exclusiveMaximum": 100},
+      "count": {"type": "integer"},
+      "note": {"type": ["string", "null"], "minLength": 2},
+      "kind": {"type": "string", "enum": ["A", "B"]},
+      "a/b~c": {"type": "boolean"},
+      "items": {
+        "type": "array",
+        "minItems": 1,
+        "items": {
+          "type": "object",
+          "properties": {"id": {"type": "string"}},
+          "required": ["id"],
+          "additionalProperties": false
+        }
+      }
+    },
+    "required": ["start_date", "amount"],
+    "additionalProperties": false
+  }
+}`
+
+func loadTestSchema(t *testing.T) *Schema {
+	t.Helper()
+	loaded, err := Load(fstest.MapFS{"test_mutation.json": {Data: []byte(testDefinition)}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return loaded["test_mutation"]
+}
+
+func TestValidateReportsViolations(t *testing.T) {
+	s := loadTestSchema(t)
+	for _, tc := range []struct {
+		name  string
+		props string
+		want  []Violation
+	}{
+		{"valid", `{"start_date":"2020-01-01","amount":10,"count":3,"note":null,"kind":"A","a/b~c":true,"items":[{"id":"x"}]}`, nil},
+		{"required", `{}`, []Violation{
+			{"/start_date", "is required"},
+			{"/amount", "is required"},
+		}},
+		{"type", `{"start_date":20200101,"amount":"10","count":1.5,"note":false}`, []Violation{
+			{"/amount", "must be of type number"},
+			{"/count", "must be of type integer"},
+			{"/note", "must be of type string or null"},
+			{"/start_date", "must be of type string"},
+		}},
+		{"format", `{"start_date":"2020-02-30","amount":1}`, []Violation{
+			{"/start_date", "must be a valid date"},
+		}},
+		{"additionalProperties", `{"start_date":"2020-01-01","amount":1,"extra":1,"items":[{"id":"x","y":2}]}`, []Violation{
+			{"/extra", "is not an allowed property"},
+			{"/items/0/y", "is not an allowed property"},
+		}},
+		{"bounds", `{"start_date":"2020-01-01","amount":100,"note":"x","kind":"C","items":[]}`, []Violation{
+			{"/amount", "must be < 100"},
+			{"/items", "must contain at least 1 items"},
+			{"/kind", "must be one of the allowed values"},
+			{"/note", "must be at least 2 characters"},
+		}},
+		{"escaped pointer", `{"start_date":"2020-01-01","amount":1,"a/b~c":"yes","items":[{}]}`, []Violation{
+			{"/a~1b~0c", "must be of type boolean"},
+			{"/items/0/id", "is required"},
+		}},
+		{"root type", `[]`, []Violation{
+			{"", "must be of type object"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tc.props), &v); err != nil {
+				t.Fatal(err)
+			}
+			var got []Violation
+			s.validate(v, "", &got)
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("violation %d: expected %v, got %v", i, tc.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUsesMutationDefinitions(t *testing.T) {
+	got := Validate("add_policy", []byte(`{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":-1}`))
+	want := []Violation{
+		{"/part_time_factor", "is required"},
+		{"/salary", "must be >= 0"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := Validate("add_policy", []byte(`{"scheme_id":`)); len(got) != 1 || got[0].Message != "is not valid JSON" {
+		t.Fatalf("expected invalid JSON to be reported, got %v", got)
+	}
+	if got := Validate("no_such_mutation", []byte(`{"anything":1}`)); got != nil {
+		t.Fatalf("expected mutations without a definition to pass, got %v", got)
+	}
+}
+
+func TestLoadRejectsBadDefinitions(t *testing.T) {
+	for name, data := range map[string]string{
+		"malformed":      `{"name":`,
+		"no name":        `{"json_schema":{"type":"object"}}`,
+		"no json_schema": `{"name":"test_mutation"}`,
+	} {
+		_, err := Load(fstest.MapFS{"bad.json": {Data: []byte(data)}})
+		var le *LoadError
+		if !errors.As(err, &le) || le.File != "bad.json" {
+			t.Errorf("%s: expected a LoadError for bad.json, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package schemeregistry
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultCacheTTL is how long a fetched scheme is served before it is
+	// refreshed.
+	defaultCacheTTL = 5 * time.Minute
+	// defaultMaxStale is how long past its TTL a scheme that cannot be
+	// refreshed is still served.
+	defaultMaxStale = time.Hour
+	// refreshBackoff is how long a failed refresh is not retried, so an
+	// unreachable registry is not asked again on every lookup.
+	refreshBackoff = 30 * time.Second
+)
+
+// CacheStats counts how the registry cache served scheme lookups since the
+// process started.
+type CacheStats struct {
+	// Hits are lookups served from the cache, fresh or stale.
+	Hits uint64
+	// Misses are lookups that had to wait for the registry.
+	Misses uint64
+	// Refreshes are background fetches of stale schemes.
+	Refreshes uint64
+	// Errors are fetches, for a miss or a refresh, that failed.
+	Errors uint64
+}
+
+// CacheEntry describes one cached scheme.
+type CacheEntry struct {
+	SchemeID  string
+	FetchedAt time.Time
+	// Stale is set once the entry is older than the TTL. It is still served
+	// while a refresh is attempted, up to the max-stale bound.
+	Stale bool
+}
+
+type cacheEntry struct {
+	scheme    Scheme
+	fetchedAt time.Time
+	// failedAt is when the last refresh failed
+	failedAt time.Time
+}
+
+// schemeCache holds the schemes fetched from the registry. An entry older
+// than ttl keeps being served while a single background fetch replaces it;
+// a failed refresh is retried after backoff, and an entry older than ttl
+// plus maxStale is fetched again as if it were not cached. Concurrent
+// fetches of the same scheme are coalesced into one request.
+type schemeCache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+	// gen changes on every purge, so fetches started before it are not
+	// stored after it
+	gen      uint64
+	ttl      time.Duration
+	maxStale time.Duration
+	backoff  time.Duration
+	group    singleflight.Group
+
+	hits, misses, refreshes, errors atomic.Uint64
+}
+
+func newSchemeCache(ttl time.Duration) *schemeCache {
+	return &schemeCache{
+		entries:  make(map[string]cacheEntry),
+		ttl:      ttl,
+		maxStale: defaultMaxStale,
+		backoff:  refreshBackoff,
+	}
+}
+
+// get returns the cached scheme, starting a background refresh when it is
+// stale. ok is false when the scheme is not cached or is past the max-stale
+// bound.
+func (c *schemeCache) get(baseURL, schemeID string) (s Scheme, ok bool) {
+	c.mu.RLock()
+	e, ok := c.entries[schemeID]
+	ttl, maxStale, backoff := c.ttl, c.maxStale, c.backoff
+	c.mu.RUnlock()
+	age := time.Since(e.fetchedAt)
+	if !ok || ttl > 0 && maxStale > 0 && age >= ttl+maxStale {
+		c.misses.Add(1)
+		return Scheme{}, false
+	}
+	c.hits.Add(1)
+	if ttl > 0 && age >= ttl && time.Since(e.failedAt) >= backoff {
+		// The result is delivered to a buffered channel nobody waits on
+		c.group.DoChan(baseURL+"/schemes/"+schemeID, func() (interface{}, error) {
+			c.refreshes.Add(1)
+			return c.load(baseURL, schemeID)
+		})
+	}
+	return e.scheme, true
+}
+
+// fetch asks the registry for a scheme that is not cached, joining a fetch
+// of the same scheme already in flight. A failure gives DefaultScheme with
+// Err set.
+func (c *schemeCache) fetch(baseURL, schemeID string) Scheme {
+	v, err, _ := c.group.Do(baseURL+"/schemes/"+schemeID, func() (interface{}, error) {
+		return c.load(baseURL, schemeID)
+	})
+	if err != nil {
+		return unavailable(schemeID, err)
+	}
+	return v.(Scheme)
+}
+
+// load fetches a scheme and caches it unless the cache was purged meanwhile.
+// A failure is recorded on the cached entry, if any, to back off its refresh.
+func (c *schemeCache) load(baseURL, schemeID string) (interface{}, error) {
+	c.mu.RLock()
+	gen := c.gen
+	c.mu.RUnlock()
+
+	s, err := fetchScheme(baseURL, schemeID)
+	if err != nil {
+		c.errors.Add(1)
+		c.mu.Lock()
+		if e, ok := c.entries[schemeID]; ok && c.gen == gen {
+			e.failedAt = time.Now()
+			c.entries[schemeID] = e
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.entries[schemeID] = cacheEntry{scheme: s, fetchedAt: time.Now()}
+	}
+	c.mu.Unlock()
+	return s, nil
+}
+
+// purge drops the given schemes, or every scheme when none are given, and
+// returns how many were cached.
+func (c *schemeCache) purge(schemeIDs ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if len(schemeIDs) == 0 {
+		n := len(c.entries)
+		c.entries = make(map[string]cacheEntry)
+		return n
+	}
+	n := 0
+	for _, id := range schemeIDs {
+		if _, ok := c.entries[id]; ok {
+			delete(c.entries, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *schemeCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+func (c *schemeCache) setMaxStale(maxStale time.Duration) {
+	c.mu.Lock()
+	c.maxStale = maxStale
+	c.mu.Unlock()
+}
+
+// SetCacheTTL sets how long a fetched scheme is served before it is
+// refreshed in the background. A ttl of zero or less never refreshes.
+func SetCacheTTL(ttl time.Duration) {
+	cache.setTTL(ttl)
+}
+
+// SetCacheMaxStale sets how long past its TTL a scheme is still served while
+// it cannot be refreshed. Beyond that it is fetched again before use, and is
+// unavailable when the registry does not answer. A maxStale of zero or less
+// serves stale schemes indefinitely.
+func SetCacheMaxStale(maxStale time.Duration) {
+	cache.setMaxStale(maxStale)
+}
+
+// PurgeCache drops the given schemes from the registry cache, or every
+// scheme when none are given, and returns how many were cached.
+func PurgeCache(schemeIDs ...string) int {
+	return cache.purge(schemeIDs...)
+}
+
+// CacheEntries lists the cached schemes ordered by ID.
+func CacheEntries() []CacheEntry {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	entries := make([]CacheEntry, 0, len(cache.entries))
+	for id, e := range cache.entries {
+		entries = append(entries, CacheEntry{
+			SchemeID:  id,
+			FetchedAt: e.fetchedAt,
+			Stale:     cache.ttl > 0 && time.Since(e.fetchedAt) >= cache.ttl,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SchemeID < entries[j].SchemeID })
+	return entries
+}
+
+// Stats returns the registry cache counters.
+func Stats() CacheStats {
+	return CacheStats{
+		Hits:      cache.hits.Load(),
+		Misses:    cache.misses.Load(),
+		Refreshes: cache.refreshes.Load(),
+		Errors:    cache.errors.Load(),
+	}
+}
@@ -0,0 +1,155 @@
+package schemeregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchemeCacheServesStaleWhileRefreshing(t *testing.T) {
+	var rate atomic.Value
+	rate.Store("0.02")
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"scheme_id":"SCHEME-A","accrual_rate":` + rate.Load().(string) + `}`))
+	}))
+	SetURL(srv.URL)
+	SetCacheTTL(200 * time.Millisecond)
+	t.Cleanup(func() {
+		SetURL("")
+		SetCacheTTL(defaultCacheTTL)
+		srv.Close()
+	})
+	accrualRate := func() float64 {
+		return Default().GetSchemes([]string{"SCHEME-A"}, DateRange{})["SCHEME-A"].AccrualRate
+	}
+	before := Stats()
+
+	if got := accrualRate(); got != 0.02 {
+		t.Fatalf("first fetch: expected 0.02, got %v", got)
+	}
+	rate.Store("0.03")
+	if got := accrualRate(); got != 0.02 {
+		t.Fatalf("fresh hit: expected 0.02, got %v", got)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one registry call while fresh, got %d", calls.Load())
+	}
+
+	// A stale entry is still served while it is refreshed in the background
+	time.Sleep(250 * time.Millisecond)
+	if got := accrualRate(); got != 0.02 {
+		t.Fatalf("stale hit: expected 0.02, got %v", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for accrualRate() != 0.03 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the refreshed rate")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	after := Stats()
+	if after.Misses-before.Misses != 1 || after.Refreshes-before.Refreshes < 1 || after.Errors != before.Errors {
+		t.Fatalf("unexpected cache counters: before %+v, after %+v", before, after)
+	}
+}
+
+func TestSchemeCacheCoalescesFetches(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"scheme_id":"SCHEME-A","accrual_rate":0.03}`))
+	}))
+	SetURL(srv.URL)
+	t.Cleanup(func() {
+		SetURL("")
+		srv.Close()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Default().GetSchemes([]string{"SCHEME-A"}, DateRange{})
+		}()
+	}
+	// Let every lookup reach the registry before it answers
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one registry call for concurrent lookups, got %d", n)
+	}
+}
+
+func TestSchemeCacheBacksOffAndBoundsStaleness(t *testing.T) {
+	var up atomic.Bool
+	up.Store(true)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !up.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"scheme_id":"SCHEME-A","accrual_rate":0.03}`))
+	}))
+	SetURL(srv.URL)
+	t.Cleanup(func() {
+		SetURL("")
+		srv.Close()
+	})
+
+	c := newSchemeCache(50 * time.Millisecond)
+	c.maxStale = 300 * time.Millisecond
+	c.backoff = time.Hour
+	if s := c.fetch(srv.URL, "SCHEME-A"); s.Err != nil || s.AccrualRate != 0.03 {
+		t.Fatalf("expected the registry scheme, got %+v", s)
+	}
+
+	// The stale entry is served while a refresh fails, and the failure is not
+	// retried within the backoff
+	up.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if s, ok := c.get(srv.URL, "SCHEME-A"); !ok || s.AccrualRate != 0.03 {
+		t.Fatalf("expected the stale scheme, got %+v, %v", s, ok)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for c.errors.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the refresh to fail")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.get(srv.URL, "SCHEME-A"); !ok {
+			t.Fatal("expected the stale scheme within max-stale")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected no refresh during the backoff, got %d registry calls", n)
+	}
+
+	// Past max-stale the entry is a miss, and the fetch it takes fails
+	time.Sleep(300 * time.Millisecond)
+	if s, ok := c.get(srv.URL, "SCHEME-A"); ok {
+		t.Fatalf("expected a miss past max-stale, got %+v", s)
+	}
+	if s := c.fetch(srv.URL, "SCHEME-A"); s.Err == nil || s.AccrualRate != defaultAccrualRate {
+		t.Fatalf("expected the scheme unavailable, got %+v", s)
+	}
+}
@@ -0,0 +1,332 @@
+package schemeregistry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	json "github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+)
+
+var errNotDefined = errors.New("not defined in the scheme directory")
+
+// reloadDelay collapses the burst of events an editor or deployment tool
+// produces for one change into a single reload.
+const reloadDelay = 100 * time.Millisecond
+
+// FileProvider is the SchemeProvider backed by a directory of scheme
+// definitions, for deployments without access to a registry service. Every
+// *.json, *.yaml and *.yml file in the directory holds one scheme, or a list
+// of schemes, in the registry payload format.
+//
+// The directory is watched and reloaded on change. A calculation keeps the
+// definitions it started with; a directory that fails validation leaves the
+// loaded definitions in place.
+type FileProvider struct {
+	dir     string
+	current atomic.Pointer[schemeSet]
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// OpenDir loads and validates the scheme definitions in dir and starts
+// watching it. Close stops the watch.
+func OpenDir(dir string) (*FileProvider, error) {
+	set, err := loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	p := &FileProvider{dir: dir, watcher: w, done: make(chan struct{})}
+	p.current.Store(set)
+	p.wg.Add(1)
+	go p.watch()
+	return p, nil
+}
+
+// Close stops watching the directory. The loaded definitions stay available.
+func (p *FileProvider) Close() error {
+	close(p.done)
+	err := p.watcher.Close()
+	p.wg.Wait()
+	return err
+}
+
+// GetSchemes returns the parameters of the given scheme IDs during r from
+// the loaded definitions.
+func (p *FileProvider) GetSchemes(schemeIDs []string, r DateRange) map[string]Scheme {
+	return p.current.Load().GetSchemes(schemeIDs, r)
+}
+
+// Version identifies the loaded definitions. It changes whenever the content
+// of a scheme file does.
+func (p *FileProvider) Version() string {
+	return p.current.Load().version
+}
+
+func (p *FileProvider) pin() SchemeProvider {
+	return p.current.Load()
+}
+
+func (p *FileProvider) watch() {
+	defer p.wg.Done()
+	var (
+		timer  *time.Timer
+		reload <-chan time.Time
+	)
+	for {
+		select {
+		case <-p.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case ev, ok := <-p.watcher.Events:
+			if !ok {
+				return
+			}
+			// Any other change may be a scheme file, or a symlink swap
+			// that replaces all of them at once
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(reloadDelay)
+			} else {
+				timer.Reset(reloadDelay)
+			}
+			reload = timer.C
+		case err, ok := <-p.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watching scheme registry %s: %v", p.dir, err)
+		case <-reload:
+			reload = nil
+			set, err := loadDir(p.dir)
+			if err != nil {
+				log.Printf("Reloading scheme registry %s: %v; keeping version %s", p.dir, err, p.Version())
+				continue
+			}
+			p.current.Store(set)
+			log.Printf("Scheme registry %s reloaded, version %s", p.dir, set.version)
+		}
+	}
+}
+
+// schemeSet is one immutable load of a scheme directory.
+type schemeSet struct {
+	schemes map[string]Scheme
+	version string
+}
+
+func (s *schemeSet) GetSchemes(schemeIDs []string, r DateRange) map[string]Scheme {
+	result := make(map[string]Scheme, len(schemeIDs))
+	for _, id := range schemeIDs {
+		sc, ok := s.schemes[id]
+		if !ok {
+			sc = unavailable(id, errNotDefined)
+		}
+		result[id] = sc.Between(r)
+	}
+	return result
+}
+
+func (s *schemeSet) Version() string {
+	return s.version
+}
+
+func isSchemeFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loadDir reads and validates every scheme file in dir. The version is a
+// digest of the file names and contents.
+func loadDir(dir string) (*schemeSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &schemeSet{schemes: make(map[string]Scheme)}
+	source := make(map[string]string)
+	digest := sha256.New()
+	// os.ReadDir sorts by name, which keeps the version stable
+	for _, e := range entries {
+		if e.IsDir() || !isSchemeFile(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(digest, "%s\x00%d\x00", e.Name(), len(data))
+		digest.Write(data)
+
+		defs, err := parseSchemeFile(e.Name(), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		for _, sr := range defs {
+			if err := sr.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.Name(), err)
+			}
+			if prev, ok := source[sr.SchemeID]; ok {
+				return nil, fmt.Errorf("%s: scheme %s is already defined in %s", e.Name(), sr.SchemeID, prev)
+			}
+			source[sr.SchemeID] = e.Name()
+			set.schemes[sr.SchemeID] = sr.scheme(sr.SchemeID)
+		}
+	}
+	set.version = hex.EncodeToString(digest.Sum(nil))[:12]
+	return set, nil
+}
+
+// parseSchemeFile decodes a file holding one scheme definition or a list of
+// them. YAML is converted to JSON first so both formats share the payload
+// field names; unknown fields are rejected to catch misspelt parameters.
+func parseSchemeFile(name string, data []byte) ([]schemeResponse, error) {
+	if ext := strings.ToLower(filepath.Ext(name)); ext == ".yaml" || ext == ".yml" {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = json.Marshal(yamlDates(v)); err != nil {
+			return nil, err
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var defs []schemeResponse
+	if err := dec.Decode(&defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// yamlDates turns the timestamps YAML decodes unquoted dates into back into
+// "YYYY-MM-DD" strings.
+func yamlDates(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format("2006-01-02")
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = yamlDates(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = yamlDates(e)
+		}
+	}
+	return v
+}
+
+// validate rejects definitions the registry service would never return.
+func (sr *schemeResponse) validate() error {
+	if sr.SchemeID == "" {
+		return errors.New("scheme_id is required")
+	}
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("scheme %s: "+format, append([]interface{}{sr.SchemeID}, args...)...)
+	}
+
+	if sr.SchemeType != "" && sr.SchemeType != SchemeTypeDB && sr.SchemeType != SchemeTypeDC {
+		return fail("scheme_type must be %q or %q", SchemeTypeDB, SchemeTypeDC)
+	}
+	if sr.IndexationPolicy != "" && sr.IndexationPolicy != IndexationFull && sr.IndexationPolicy != IndexationNone {
+		return fail("indexation_policy must be %q or %q", IndexationFull, IndexationNone)
+	}
+	if sr.AccrualRate == nil {
+		return fail("accrual_rate is required")
+	}
+	if *sr.AccrualRate < 0 {
+		return fail("accrual_rate must not be negative")
+	}
+	seen := make(map[string]bool, len(sr.AccrualRatePeriods))
+	for _, rp := range sr.AccrualRatePeriods {
+		if _, err := time.Parse("2006-01-02", rp.ValidFrom); err != nil {
+			return fail("accrual_rate_periods valid_from %q is not a date", rp.ValidFrom)
+		}
+		if seen[rp.ValidFrom] {
+			return fail("accrual_rate_periods has two periods from %s", rp.ValidFrom)
+		}
+		seen[rp.ValidFrom] = true
+		if rp.AccrualRate < 0 {
+			return fail("accrual_rate_periods accrual_rate must not be negative")
+		}
+	}
+	for _, d := range []struct{ name, value string }{{"valid_from", sr.ValidFrom}, {"valid_to", sr.ValidTo}} {
+		if _, err := time.Parse("2006-01-02", d.value); d.value != "" && err != nil {
+			return fail("%s %q is not a date", d.name, d.value)
+		}
+	}
+	if sr.ValidFrom != "" && sr.ValidTo != "" && sr.ValidTo < sr.ValidFrom {
+		return fail("valid_to is before valid_from")
+	}
+
+	if sr.NormalRetirementAge != nil && *sr.NormalRetirementAge <= 0 {
+		return fail("normal_retirement_age must be positive")
+	}
+	if sr.MinRetirementAge != nil && *sr.MinRetirementAge <= 0 {
+		return fail("min_retirement_age must be positive")
+	}
+	for _, f := range []struct {
+		name  string
+		value *float64
+	}{
+		{"survivor_pension_percentage", sr.SurvivorPercentage},
+		{"max_commutation_percentage", sr.MaxCommutation},
+	} {
+		if f.value != nil && (*f.value < 0 || *f.value > 1) {
+			return fail("%s must be between 0 and 1", f.name)
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		value *float64
+	}{
+		{"min_service_years", sr.MinServiceYears},
+		{"vesting_period_years", sr.VestingYears},
+		{"early_retirement_reduction_per_month", sr.EarlyReductionPerMonth},
+		{"late_retirement_increase_per_month", sr.LateIncreasePerMonth},
+		{"annuity_factor", sr.AnnuityFactor},
+		{"franchise", sr.Franchise},
+		{"max_pensionable_salary", sr.MaxPensionableSalary},
+	} {
+		if f.value != nil && *f.value < 0 {
+			return fail("%s must not be negative", f.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package schemeregistry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openDir writes files into a temporary directory and opens it as a scheme
+// registry.
+func openDir(t *testing.T, files map[string]string) (*FileProvider, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("OpenDir: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, dir
+}
+
+func TestFileProviderServesSchemes(t *testing.T) {
+	p, _ := openDir(t, map[string]string{
+		"scheme-a.yaml": "scheme_id: SCHEME-A\naccrual_rate: 0.0175\naccrual_rate_periods:\n  - valid_from: 2015-01-01\n    accrual_rate: 0.01875\n",
+		"others.json":   `[{"scheme_id":"SCHEME-DC","scheme_type":"DC","accrual_rate":0,"annuity_factor":20}]`,
+	})
+
+	sc := p.GetSchemes([]string{"SCHEME-A", "SCHEME-DC", "SCHEME-X"}, DateRange{})
+	a := sc["SCHEME-A"]
+	if a.Err != nil || a.AccrualRate != 0.0175 || len(a.AccrualPeriods) != 1 ||
+		a.AccrualPeriods[0].Rate != 0.01875 || a.AccrualPeriods[0].From.Format("2006-01-02") != "2015-01-01" {
+		t.Fatalf("expected SCHEME-A with its dated rate, got %+v", a)
+	}
+	if !sc["SCHEME-DC"].IsDC() {
+		t.Fatalf("expected SCHEME-DC to be a DC scheme, got %+v", sc["SCHEME-DC"])
+	}
+	if x := sc["SCHEME-X"]; x.Err == nil || x.AccrualRate != defaultAccrualRate {
+		t.Fatalf("expected SCHEME-X unavailable with the default rate, got %+v", x)
+	}
+	if p.Version() == "" {
+		t.Fatal("expected a version")
+	}
+}
+
+func TestFileProviderRejectsInvalidDefinitions(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"missing scheme_id":    {"a.json": `{"accrual_rate":0.02}`},
+		"missing accrual_rate": {"a.yaml": "scheme_id: A\nnormal_retirement_age: 67\n"},
+		"unknown field":        {"a.json": `{"scheme_id":"A","acrual_rate":0.02}`},
+		"bad scheme_type":      {"a.yaml": "scheme_id: A\naccrual_rate: 0.02\nscheme_type: CDC\n"},
+		"bad period date":      {"a.json": `{"scheme_id":"A","accrual_rate":0.02,"accrual_rate_periods":[{"valid_from":"2015-13-01","accrual_rate":0.02}]}`},
+		"percentage > 1":       {"a.json": `{"scheme_id":"A","accrual_rate":0.02,"survivor_pension_percentage":1.5}`},
+		"duplicate id":         {"a.json": `{"scheme_id":"A","accrual_rate":0.02}`, "b.yml": "scheme_id: A\naccrual_rate: 0.02\n"},
+		"malformed":            {"a.json": `{"scheme_id":`},
+	} {
+		dir := t.TempDir()
+		for file, content := range files {
+			os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644)
+		}
+		if p, err := OpenDir(dir); err == nil {
+			p.Close()
+			t.Errorf("%s: expected OpenDir to fail", name)
+		}
+	}
+}
+
+func TestFileProviderReloadsOnChange(t *testing.T) {
+	p, dir := openDir(t, map[string]string{"a.json": `{"scheme_id":"SCHEME-A","accrual_rate":0.02}`})
+	pinned := NewSnapshot(p)
+	before := p.Version()
+
+	// An invalid change keeps the loaded definitions
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"scheme_id":"SCHEME-B","accrual_rate":-1}`), 0o644)
+	time.Sleep(500 * time.Millisecond)
+	if p.Version() != before {
+		t.Fatal("expected an invalid directory to keep the loaded version")
+	}
+
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"scheme_id":"SCHEME-B","accrual_rate":0.03}`), 0o644)
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Version() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the directory to be reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if rate := p.GetSchemes([]string{"SCHEME-B"}, DateRange{})["SCHEME-B"].AccrualRate; rate != 0.03 {
+		t.Fatalf("expected the reloaded rate 0.03, got %v", rate)
+	}
+	// A calculation that started before the reload keeps its definitions
+	if rate := pinned.GetSchemes([]string{"SCHEME-B"}, DateRange{})["SCHEME-B"].AccrualRate; rate != 0.02 {
+		t.Fatalf("expected the pinned default rate 0.02, got %v", rate)
+	}
+	if v := Version(pinned); v != before {
+		t.Fatalf("expected the pinned version %q, got %q", before, v)
+	}
+}
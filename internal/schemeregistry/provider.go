@@ -0,0 +1,124 @@
+package schemeregistry
+
+import "sort"
+
+// SchemeProvider supplies scheme parameters to the mutation handlers. The
+// returned map holds an entry for every requested ID; schemes the provider
+// does not know get DefaultScheme. The schemes carry the parameters in
+// effect during r; a zero DateRange asks for all of them. A scheme that is
+// not valid on r.To is unavailable.
+type SchemeProvider interface {
+	GetSchemes(schemeIDs []string, r DateRange) map[string]Scheme
+}
+
+var defaultProvider SchemeProvider = httpProvider{}
+
+// Default returns the provider set by SetDefault or, without one, the
+// registry at SCHEME_REGISTRY_URL, which gives default parameters for every
+// scheme when it is not set.
+func Default() SchemeProvider {
+	return defaultProvider
+}
+
+// SetDefault makes p the provider returned by Default. A nil p restores the
+// registry at SCHEME_REGISTRY_URL. It is meant to be called at startup,
+// before any calculation runs.
+func SetDefault(p SchemeProvider) {
+	if p == nil {
+		p = httpProvider{}
+	}
+	defaultProvider = p
+}
+
+// Version returns the version of the scheme definitions p serves, or "" when
+// p does not track one.
+func Version(p SchemeProvider) string {
+	if v, ok := p.(interface{ Version() string }); ok {
+		return v.Version()
+	}
+	return ""
+}
+
+// pinner is implemented by providers whose definitions can change while a
+// calculation runs. pin returns a provider fixed to the current definitions.
+type pinner interface {
+	pin() SchemeProvider
+}
+
+// Snapshot memoizes the schemes of one calculation. It is not safe for
+// concurrent use.
+type Snapshot struct {
+	base    SchemeProvider
+	schemes map[string]Scheme
+	// failed holds the errors of unavailable schemes not yet taken by
+	// Failures
+	failed []error
+}
+
+// NewSnapshot returns a provider that asks base for the whole timeline of
+// each scheme once and afterwards serves it from memory, so every mutation
+// of a calculation sees the same parameters without new registry calls. A
+// base that reloads its definitions is pinned to those loaded now.
+func NewSnapshot(base SchemeProvider) *Snapshot {
+	if p, ok := base.(pinner); ok {
+		base = p.pin()
+	}
+	return &Snapshot{base: base}
+}
+
+// Version returns the version of the definitions the snapshot reads.
+func (s *Snapshot) Version() string {
+	return Version(s.base)
+}
+
+// Failures returns, ordered by scheme ID, the errors of the unavailable
+// schemes looked up since the previous call. A scheme the provider could not
+// load is not asked for again, so it is reported once; a scheme looked up for
+// a date outside its validity is reported by every such lookup.
+func (s *Snapshot) Failures() []error {
+	if len(s.failed) == 0 {
+		return nil
+	}
+	errs := s.failed
+	// An UnavailableError message starts with the scheme ID
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	s.failed = nil
+	return errs
+}
+
+func (s *Snapshot) GetSchemes(schemeIDs []string, r DateRange) map[string]Scheme {
+	result := make(map[string]Scheme, len(schemeIDs))
+	var missing []string
+	for _, id := range schemeIDs {
+		if sc, ok := s.schemes[id]; ok {
+			result[id] = s.between(sc, r)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return result
+	}
+
+	if s.schemes == nil {
+		s.schemes = make(map[string]Scheme, len(missing))
+	}
+	for id, sc := range s.base.GetSchemes(missing, DateRange{}) {
+		s.schemes[id] = sc
+		if sc.Err != nil {
+			s.failed = append(s.failed, sc.Err)
+		}
+		result[id] = s.between(sc, r)
+	}
+	return result
+}
+
+// between returns sc.Between(r), recording the failure when sc is not valid
+// during r.
+func (s *Snapshot) between(sc Scheme, r DateRange) Scheme {
+	in := sc.Between(r)
+	if in.Err != nil && sc.Err == nil {
+		s.failed = append(s.failed, in.Err)
+	}
+	return in
+}
@@ -1,9 +1,14 @@
 package schemeregistry
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,15 +17,108 @@ import (
 
 var (
 	registryURL string
-	cache       sync.Map
+	cache       = newSchemeCache(defaultCacheTTL)
 	client      *http.Client
 )
 
-const defaultAccrualRate = 0.02
+const (
+	defaultAccrualRate         = 0.02
+	defaultNormalRetirementAge = 65
+	defaultSurvivorPercentage  = 0.7
+	defaultMaxCommutation      = 0.25
+	defaultMinServiceYears     = 40
+)
+
+// Scheme types. A defined benefit scheme accrues a pension from salary and
+// service; a defined contribution scheme builds up capital that is converted
+// to a pension at retirement.
+const (
+	SchemeTypeDB = "DB"
+	SchemeTypeDC = "DC"
+)
+
+// Indexation policies. apply_indexation raises the salaries of policies in a
+// scheme with full indexation and leaves those in a scheme without alone.
+const (
+	IndexationFull = "FULL"
+	IndexationNone = "NONE"
+)
+
+// Failure modes decide how a calculation treats a scheme the provider could
+// not load.
+const (
+	// FailureWarn continues with DefaultScheme and reports a warning.
+	FailureWarn = "warn"
+	// FailureStrict fails the mutation that needs the scheme.
+	FailureStrict = "strict"
+)
+
+var failureMode = FailureWarn
 
 func init() {
-	registryURL = os.Getenv("SCHEME_REGISTRY_URL")
-	if registryURL != "" {
+	SetURL(os.Getenv("SCHEME_REGISTRY_URL"))
+	if ttl := os.Getenv("SCHEME_REGISTRY_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Printf("Ignoring SCHEME_REGISTRY_CACHE_TTL %q: %v", ttl, err)
+		} else {
+			SetCacheTTL(d)
+		}
+	}
+	if maxStale := os.Getenv("SCHEME_REGISTRY_CACHE_MAX_STALE"); maxStale != "" {
+		d, err := time.ParseDuration(maxStale)
+		if err != nil {
+			log.Printf("Ignoring SCHEME_REGISTRY_CACHE_MAX_STALE %q: %v", maxStale, err)
+		} else {
+			SetCacheMaxStale(d)
+		}
+	}
+	SetFailureMode(os.Getenv("SCHEME_REGISTRY_FAILURE_MODE"))
+}
+
+// SetFailureMode selects how unavailable schemes are treated. Any value other
+// than FailureStrict selects FailureWarn.
+func SetFailureMode(mode string) {
+	if mode == FailureStrict {
+		failureMode = FailureStrict
+		return
+	}
+	failureMode = FailureWarn
+}
+
+// Strict reports whether an unavailable scheme fails the calculation.
+func Strict() bool {
+	return failureMode == FailureStrict
+}
+
+// UnavailableError records why a provider could not load a scheme.
+type UnavailableError struct {
+	SchemeID string
+	Err      error
+}
+
+func (e *UnavailableError) Error() string {
+	return "scheme " + e.SchemeID + ": " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// unavailable returns DefaultScheme carrying the reason the scheme could not
+// be loaded.
+func unavailable(schemeID string, err error) Scheme {
+	s := DefaultScheme(schemeID)
+	s.Err = &UnavailableError{SchemeID: schemeID, Err: err}
+	return s
+}
+
+// SetURL points the registry client at url and clears the cache. An empty
+// url disables fetching so every scheme gets the default parameters.
+func SetURL(url string) {
+	registryURL = url
+	cache.purge()
+	if registryURL != "" && client == nil {
 		client = &http.Client{
 			Timeout: 2 * time.Second,
 			Transport: &http.Transport{
@@ -32,27 +130,274 @@ func init() {
 	}
 }
 
+// Scheme holds the parameters of one pension scheme.
+type Scheme struct {
+	SchemeID string
+	// Type is SchemeTypeDB or SchemeTypeDC.
+	Type string
+	// AccrualRate applies to service before the first of AccrualPeriods, or
+	// throughout when there are none.
+	AccrualRate float64
+	// AccrualPeriods are the dated accrual rates, ordered by From.
+	AccrualPeriods []RatePeriod
+	// NormalRetirementAge is the age in years at which no actuarial
+	// adjustment applies.
+	NormalRetirementAge int
+	// MinRetirementAge is the earliest age in years at which the scheme
+	// allows early retirement. It never exceeds NormalRetirementAge.
+	MinRetirementAge int
+	// MinServiceYears is the total years of service that make a participant
+	// eligible for retirement at any age, without early retirement reduction.
+	MinServiceYears float64
+	// VestingYears is the service a policy needs before it grants a pension.
+	VestingYears float64
+	// EarlyReductionPerMonth is subtracted from the adjustment factor for
+	// every full month retirement starts before NormalRetirementAge.
+	EarlyReductionPerMonth float64
+	// LateIncreasePerMonth is added to the adjustment factor for every full
+	// month retirement starts after NormalRetirementAge.
+	LateIncreasePerMonth float64
+	// SurvivorPercentage is the share of the attainable pension paid to the
+	// partner after the participant's death.
+	SurvivorPercentage float64
+	// AnnuityFactor converts one unit of commuted annual pension into a lump
+	// sum. Zero means the scheme does not supply one.
+	AnnuityFactor float64
+	// MaxCommutationPercentage is the largest share of the annual pension
+	// that may be exchanged for a lump sum.
+	MaxCommutationPercentage float64
+	// AssumedReturnRate is the yearly return used to project the capital of
+	// a DC scheme.
+	AssumedReturnRate float64
+	// Indexation is IndexationFull or IndexationNone.
+	Indexation string
+	// ValidFrom and ValidTo bound the dates, inclusive, on which the scheme
+	// parameters apply. A zero time leaves that side unbounded.
+	ValidFrom time.Time
+	ValidTo   time.Time
+	// Franchise is subtracted from the (capped) salary before accrual.
+	Franchise float64
+	// MaxPensionableSalary caps the salary that accrues pension. Zero means
+	// no cap.
+	MaxPensionableSalary float64
+	// Err is set when the provider could not load the scheme. The other
+	// fields are then those of DefaultScheme.
+	Err error
+}
+
+// DefaultScheme returns the parameters used when the registry is not
+// configured or cannot be reached: a DB scheme, valid at all times, with 0.02
+// accrual over the full salary (no franchise, no cap), full indexation and
+// immediate vesting, retirement at 65 or after 40 years of service with no
+// early retirement and no adjustment factors, a 70% survivor pension, and
+// commutation of at most 25% without an annuity factor.
+func DefaultScheme(schemeID string) Scheme {
+	return Scheme{
+		SchemeID:                 schemeID,
+		Type:                     SchemeTypeDB,
+		Indexation:               IndexationFull,
+		AccrualRate:              defaultAccrualRate,
+		NormalRetirementAge:      defaultNormalRetirementAge,
+		MinRetirementAge:         defaultNormalRetirementAge,
+		MinServiceYears:          defaultMinServiceYears,
+		SurvivorPercentage:       defaultSurvivorPercentage,
+		MaxCommutationPercentage: defaultMaxCommutation,
+	}
+}
+
+// AdjustmentFactor returns the actuarial factor for a retirement starting
+// at ageMonths (full months). The result is never negative.
+func (s Scheme) AdjustmentFactor(ageMonths int) float64 {
+	diff := ageMonths - s.NormalRetirementAge*12
+	var f float64
+	switch {
+	case diff < 0:
+		f = 1 + float64(diff)*s.EarlyReductionPerMonth
+	case diff > 0:
+		f = 1 + float64(diff)*s.LateIncreasePerMonth
+	default:
+		return 1
+	}
+	if f < 0 {
+		return 0
+	}
+	return f
+}
+
+// RatePeriod is an accrual rate in effect from From until the From of the
+// next period.
+type RatePeriod struct {
+	From time.Time
+	Rate float64
+}
+
+// DateRange is an inclusive range of dates. A zero From or To leaves that
+// side unbounded.
+type DateRange struct {
+	From, To time.Time
+}
+
+// Between returns s with only the accrual periods in effect at some date of
+// r. When periods before r are dropped, AccrualRate becomes the rate of the
+// last of them, so it still matches the rate just before the first kept
+// period; rates are only meaningful from r.From on. A scheme that is not
+// valid on r.To, the date the parameters are asked for, is unavailable.
+func (s Scheme) Between(r DateRange) Scheme {
+	if !r.To.IsZero() && (r.To.Before(s.ValidFrom) || !s.ValidTo.IsZero() && r.To.After(s.ValidTo)) {
+		return unavailable(s.SchemeID, fmt.Errorf("not valid on %s", r.To.Format("2006-01-02")))
+	}
+	if len(s.AccrualPeriods) == 0 {
+		return s
+	}
+	first, last := 0, len(s.AccrualPeriods)
+	for i := range s.AccrualPeriods {
+		// A period ends where the next one starts
+		if !r.From.IsZero() && i+1 < len(s.AccrualPeriods) && !s.AccrualPeriods[i+1].From.After(r.From) {
+			first = i + 1
+		}
+		if !r.To.IsZero() && s.AccrualPeriods[i].From.After(r.To) {
+			last = i
+			break
+		}
+	}
+	// Without a period in effect, AccrualRate applies throughout r
+	if first >= last {
+		s.AccrualPeriods = nil
+		return s
+	}
+	if first > 0 {
+		s.AccrualRate = s.AccrualPeriods[first-1].Rate
+	}
+	s.AccrualPeriods = s.AccrualPeriods[first:last:last]
+	return s
+}
+
+// IsDC reports whether s is a defined contribution scheme.
+func (s Scheme) IsDC() bool {
+	return s.Type == SchemeTypeDC
+}
+
+// schemeResponse is the registry payload. Only scheme_id and accrual_rate
+// are mandatory; a response without accrual_rate is rejected.
+// accrual_rate_periods with an invalid valid_from are ignored, as are a
+// valid_from or valid_to that are not dates, omitted parameters keep their
+// defaults, any scheme_type other than "DC" is a DB scheme and any
+// indexation_policy other than "NONE" is full indexation.
 type schemeResponse struct {
-	SchemeID    string  `json:"scheme_id"`
-	AccrualRate float64 `json:"accrual_rate"`
+	SchemeID           string `json:"scheme_id"`
+	SchemeType         string `json:"scheme_type"`
+	AccrualRatePeriods []struct {
+		ValidFrom   string  `json:"valid_from"`
+		AccrualRate float64 `json:"accrual_rate"`
+	} `json:"accrual_rate_periods"`
+	IndexationPolicy       string   `json:"indexation_policy"`
+	ValidFrom              string   `json:"valid_from"`
+	ValidTo                string   `json:"valid_to"`
+	AccrualRate            *float64 `json:"accrual_rate"`
+	NormalRetirementAge    *int     `json:"normal_retirement_age"`
+	MinRetirementAge       *int     `json:"min_retirement_age"`
+	MinServiceYears        *float64 `json:"min_service_years"`
+	VestingYears           *float64 `json:"vesting_period_years"`
+	EarlyReductionPerMonth *float64 `json:"early_retirement_reduction_per_month"`
+	LateIncreasePerMonth   *float64 `json:"late_retirement_increase_per_month"`
+	SurvivorPercentage     *float64 `json:"survivor_pension_percentage"`
+	AnnuityFactor          *float64 `json:"annuity_factor"`
+	MaxCommutation         *float64 `json:"max_commutation_percentage"`
+	AssumedReturnRate      *float64 `json:"assumed_return_rate"`
+	Franchise              *float64 `json:"franchise"`
+	MaxPensionableSalary   *float64 `json:"max_pensionable_salary"`
+}
+
+func (sr *schemeResponse) scheme(schemeID string) Scheme {
+	s := DefaultScheme(schemeID)
+	if sr.SchemeType == SchemeTypeDC {
+		s.Type = SchemeTypeDC
+	}
+	if sr.IndexationPolicy == IndexationNone {
+		s.Indexation = IndexationNone
+	}
+	s.ValidFrom, _ = time.Parse("2006-01-02", sr.ValidFrom)
+	s.ValidTo, _ = time.Parse("2006-01-02", sr.ValidTo)
+	s.AccrualRate = *sr.AccrualRate
+	for _, rp := range sr.AccrualRatePeriods {
+		from, err := time.Parse("2006-01-02", rp.ValidFrom)
+		if err != nil {
+			continue
+		}
+		s.AccrualPeriods = append(s.AccrualPeriods, RatePeriod{From: from, Rate: rp.AccrualRate})
+	}
+	sort.Slice(s.AccrualPeriods, func(i, j int) bool { return s.AccrualPeriods[i].From.Before(s.AccrualPeriods[j].From) })
+	if sr.NormalRetirementAge != nil {
+		s.NormalRetirementAge = *sr.NormalRetirementAge
+	}
+	s.MinRetirementAge = s.NormalRetirementAge
+	if sr.MinRetirementAge != nil && *sr.MinRetirementAge < s.NormalRetirementAge {
+		s.MinRetirementAge = *sr.MinRetirementAge
+	}
+	if sr.MinServiceYears != nil {
+		s.MinServiceYears = *sr.MinServiceYears
+	}
+	if sr.VestingYears != nil {
+		s.VestingYears = *sr.VestingYears
+	}
+	if sr.EarlyReductionPerMonth != nil {
+		s.EarlyReductionPerMonth = *sr.EarlyReductionPerMonth
+	}
+	if sr.LateIncreasePerMonth != nil {
+		s.LateIncreasePerMonth = *sr.LateIncreasePerMonth
+	}
+	if sr.SurvivorPercentage != nil {
+		s.SurvivorPercentage = *sr.SurvivorPercentage
+	}
+	if sr.AnnuityFactor != nil {
+		s.AnnuityFactor = *sr.AnnuityFactor
+	}
+	if sr.MaxCommutation != nil {
+		s.MaxCommutationPercentage = *sr.MaxCommutation
+	}
+	if sr.AssumedReturnRate != nil {
+		s.AssumedReturnRate = *sr.AssumedReturnRate
+	}
+	if sr.Franchise != nil {
+		s.Franchise = *sr.Franchise
+	}
+	if sr.MaxPensionableSalary != nil {
+		s.MaxPensionableSalary = *sr.MaxPensionableSalary
+	}
+	return s
 }
 
-// GetAccrualRates fetches accrual rates for the given scheme IDs.
-// Uses caching and concurrent fetching. Falls back to 0.02 on error.
-func GetAccrualRates(schemeIDs []string) map[string]float64 {
-	result := make(map[string]float64, len(schemeIDs))
+// httpProvider is the SchemeProvider backed by SCHEME_REGISTRY_URL.
+type httpProvider struct{}
 
-	if registryURL == "" {
+// GetSchemes returns the parameters of the given scheme IDs during r. The
+// registry is asked for the whole timeline of a scheme, which is cached.
+func (httpProvider) GetSchemes(schemeIDs []string, r DateRange) map[string]Scheme {
+	schemes := getSchemes(schemeIDs)
+	for id, sc := range schemes {
+		schemes[id] = sc.Between(r)
+	}
+	return schemes
+}
+
+// getSchemes fetches the parameters of the given scheme IDs.
+// Uses caching and concurrent fetching. A scheme that cannot be fetched gets
+// DefaultScheme with Err set and is not cached, so the next call retries it.
+func getSchemes(schemeIDs []string) map[string]Scheme {
+	result := make(map[string]Scheme, len(schemeIDs))
+
+	baseURL := registryURL
+	if baseURL == "" {
 		for _, id := range schemeIDs {
-			result[id] = defaultAccrualRate
+			result[id] = DefaultScheme(id)
 		}
 		return result
 	}
 
 	var toFetch []string
 	for _, id := range schemeIDs {
-		if rate, ok := cache.Load(id); ok {
-			result[id] = rate.(float64)
+		if s, ok := cache.get(baseURL, id); ok {
+			result[id] = s
 		} else {
 			toFetch = append(toFetch, id)
 		}
@@ -63,9 +408,7 @@ func GetAccrualRates(schemeIDs []string) map[string]float64 {
 	}
 
 	if len(toFetch) == 1 {
-		rate := fetchRate(toFetch[0])
-		cache.Store(toFetch[0], rate)
-		result[toFetch[0]] = rate
+		result[toFetch[0]] = cache.fetch(baseURL, toFetch[0])
 		return result
 	}
 
@@ -76,10 +419,9 @@ func GetAccrualRates(schemeIDs []string) map[string]float64 {
 		wg.Add(1)
 		go func(schemeID string) {
 			defer wg.Done()
-			rate := fetchRate(schemeID)
-			cache.Store(schemeID, rate)
+			s := cache.fetch(baseURL, schemeID)
 			mu.Lock()
-			result[schemeID] = rate
+			result[schemeID] = s
 			mu.Unlock()
 		}(id)
 	}
@@ -88,21 +430,29 @@ func GetAccrualRates(schemeIDs []string) map[string]float64 {
 	return result
 }
 
-func fetchRate(schemeID string) float64 {
-	resp, err := client.Get(registryURL + "/schemes/" + schemeID)
+func fetchScheme(baseURL, schemeID string) (Scheme, error) {
+	resp, err := client.Get(baseURL + "/schemes/" + schemeID)
 	if err != nil {
-		return defaultAccrualRate
+		// The cause without the request URL, which messages should not expose
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return Scheme{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		io.Copy(io.Discard, resp.Body)
-		return defaultAccrualRate
+		return Scheme{}, fmt.Errorf("registry responded %s", resp.Status)
 	}
 
 	var sr schemeResponse
 	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-		return defaultAccrualRate
+		return Scheme{}, fmt.Errorf("decoding registry response: %w", err)
+	}
+	if sr.AccrualRate == nil {
+		return Scheme{}, errors.New("registry response has no accrual_rate")
 	}
-	return sr.AccrualRate
+	return sr.scheme(schemeID), nil
 }
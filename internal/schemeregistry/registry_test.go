@@ -0,0 +1,49 @@
+package schemeregistry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchemeBetweenKeepsRatesInRange(t *testing.T) {
+	day := func(s string) time.Time { d, _ := time.Parse("2006-01-02", s); return d }
+	sc := DefaultScheme("SCHEME-A")
+	sc.AccrualPeriods = []RatePeriod{
+		{From: day("2005-01-01"), Rate: 0.021},
+		{From: day("2010-01-01"), Rate: 0.022},
+		{From: day("2015-01-01"), Rate: 0.023},
+	}
+
+	got := sc.Between(DateRange{From: day("2012-06-01"), To: day("2014-12-31")})
+	if len(got.AccrualPeriods) != 1 || got.AccrualPeriods[0].Rate != 0.022 {
+		t.Fatalf("expected only the 2010 period, got %+v", got.AccrualPeriods)
+	}
+	// Service before the 2010 period keeps the 2005 rate, not the base rate
+	if got.AccrualRate != 0.021 {
+		t.Fatalf("expected the rate of the dropped 2005 period, got %v", got.AccrualRate)
+	}
+	if got := sc.Between(DateRange{To: day("2004-12-31")}); got.AccrualPeriods != nil || got.AccrualRate != 0.02 {
+		t.Fatalf("expected the base rate only, got %+v", got)
+	}
+}
+
+func TestSchemeBetweenChecksValidity(t *testing.T) {
+	day := func(s string) time.Time { d, _ := time.Parse("2006-01-02", s); return d }
+	sc := DefaultScheme("SCHEME-A")
+	sc.AccrualRate = 0.03
+	sc.ValidFrom, sc.ValidTo = day("2000-01-01"), day("2020-12-31")
+
+	for _, date := range []string{"2000-01-01", "2010-06-15", "2020-12-31"} {
+		if got := sc.Between(DateRange{To: day(date)}); got.Err != nil || got.AccrualRate != 0.03 {
+			t.Fatalf("%s: expected the scheme, got %+v", date, got)
+		}
+	}
+	for _, date := range []string{"1999-12-31", "2021-01-01"} {
+		if got := sc.Between(DateRange{To: day(date)}); got.Err == nil || got.AccrualRate != defaultAccrualRate {
+			t.Fatalf("%s: expected the scheme unavailable, got %+v", date, got)
+		}
+	}
+	if got := sc.Between(DateRange{}); got.Err != nil {
+		t.Fatalf("expected a lookup without a date to ignore the validity, got %+v", got)
+	}
+}
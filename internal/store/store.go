@@ -0,0 +1,332 @@
+// Package store persists dossiers in an append-only log on local disk so
+// new mutations can be calculated on top of a stored end situation.
+//
+// Every record carries the full end situation of its dossier and the log is
+// never compacted: it grows with each append, and Open replays all of it.
+// Dossiers with long histories or many appends make the log, and startup,
+// correspondingly larger.
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	json "github.com/goccy/go-json"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+)
+
+const logFileName = "dossiers.log"
+
+var (
+	// ErrDuplicateMutation is returned when a mutation_id was already applied
+	// to the dossier, or appears twice in the same request.
+	ErrDuplicateMutation = errors.New("mutation already applied")
+	// ErrDossierMismatch is returned when the mutations create or address a
+	// dossier other than the one being appended to.
+	ErrDossierMismatch = errors.New("mutations do not belong to this dossier")
+	// ErrNotFound is returned for dossiers that are not stored.
+	ErrNotFound = errors.New("dossier not found")
+)
+
+// Dossier is the stored state of a single dossier.
+type Dossier struct {
+	TenantID     string                  `json:"tenant_id"`
+	DossierID    string                  `json:"dossier_id"`
+	Mutations    []model.Mutation        `json:"mutations"`
+	EndSituation model.SituationEnvelope `json:"end_situation"`
+	PolicySeq    int                     `json:"policy_seq"`
+}
+
+// record is one line of the log. Mutations holds only the mutations
+// appended by that write, or the complete history when Replace is set;
+// EndSituation is the situation after them.
+type record struct {
+	TenantID     string                  `json:"tenant_id"`
+	DossierID    string                  `json:"dossier_id"`
+	Replace      bool                    `json:"replace,omitempty"`
+	Mutations    []model.Mutation        `json:"mutations"`
+	EndSituation model.SituationEnvelope `json:"end_situation"`
+	PolicySeq    int                     `json:"policy_seq"`
+}
+
+type key struct {
+	tenantID  string
+	dossierID string
+}
+
+type entry struct {
+	Dossier
+	applied map[string]struct{}
+}
+
+// Store is a file-backed dossier store keyed by tenant_id and dossier_id.
+// It is safe for concurrent use. Writes to one dossier are serialised;
+// calculations for different dossiers run concurrently.
+type Store struct {
+	// mu guards the dossiers and locks maps, never a calculation or I/O
+	mu       sync.Mutex
+	dossiers map[key]*entry
+	locks    map[key]*sync.Mutex
+
+	// fileMu serialises appends to the log
+	fileMu sync.Mutex
+	file   *os.File
+}
+
+// Open opens (or creates) the store in dir and replays its log.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{file: f, dossiers: make(map[key]*entry), locks: make(map[key]*sync.Mutex)}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the underlying log file.
+func (s *Store) Close() error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	return s.file.Close()
+}
+
+// lock locks the dossier k for a calculate-and-write cycle and returns the
+// unlock function.
+func (s *Store) lock(k key) func() {
+	s.mu.Lock()
+	l, ok := s.locks[k]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[k] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// entry returns the stored dossier k, or nil. Its fields may be read
+// without s.mu while the dossier is locked, since only the holder of that
+// lock changes them.
+func (s *Store) entry(k key) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dossiers[k]
+}
+
+func (s *Store) replay() error {
+	r := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// A write was interrupted before its newline: drop the torn tail.
+				log.Printf("store: discarding incomplete log record at offset %d", offset)
+				return s.file.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return errors.New("store: corrupt log record at offset " + strconv.FormatInt(offset, 10) + ": " + err.Error())
+		}
+		s.apply(&rec)
+		offset += int64(len(line))
+	}
+}
+
+func (s *Store) apply(rec *record) {
+	k := key{rec.TenantID, rec.DossierID}
+	e, ok := s.dossiers[k]
+	if !ok {
+		e = &entry{
+			Dossier: Dossier{TenantID: rec.TenantID, DossierID: rec.DossierID},
+			applied: make(map[string]struct{}),
+		}
+		s.dossiers[k] = e
+	}
+	if rec.Replace {
+		e.Mutations = nil
+		e.applied = make(map[string]struct{}, len(rec.Mutations))
+	}
+	e.Mutations = append(e.Mutations, rec.Mutations...)
+	for _, m := range rec.Mutations {
+		e.applied[m.MutationID] = struct{}{}
+	}
+	e.EndSituation = rec.EndSituation
+	e.EndSituation.PolicySeq = rec.PolicySeq
+	e.PolicySeq = rec.PolicySeq
+	if e.EndSituation.Situation.Dossier != nil {
+		e.EndSituation.Situation.Dossier.PolicySeq = rec.PolicySeq
+	}
+}
+
+// Get returns a copy of the stored dossier.
+func (s *Store) Get(tenantID, dossierID string) (Dossier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.dossiers[key{tenantID, dossierID}]
+	if !ok {
+		return Dossier{}, ErrNotFound
+	}
+	d := e.Dossier
+	d.Mutations = append([]model.Mutation(nil), e.Mutations...)
+	d.EndSituation.Situation = e.EndSituation.Situation.Clone()
+	return d, nil
+}
+
+// Append calculates muts on top of the stored end situation of the dossier
+// (or an empty situation for a new dossier). The mutations are persisted
+// only when the calculation succeeds; the response is returned either way.
+func (s *Store) Append(tenantID, dossierID string, muts []model.Mutation) (*model.CalculationResponse, error) {
+	k := key{tenantID, dossierID}
+	defer s.lock(k)()
+	e := s.entry(k)
+
+	seen := make(map[string]struct{}, len(muts))
+	for _, m := range muts {
+		if _, dup := seen[m.MutationID]; dup {
+			return nil, &MutationError{MutationID: m.MutationID, Err: ErrDuplicateMutation}
+		}
+		seen[m.MutationID] = struct{}{}
+		if e != nil {
+			if _, dup := e.applied[m.MutationID]; dup {
+				return nil, &MutationError{MutationID: m.MutationID, Err: ErrDuplicateMutation}
+			}
+		}
+		if m.DossierID != "" && m.DossierID != dossierID {
+			return nil, &MutationError{MutationID: m.MutationID, Err: ErrDossierMismatch}
+		}
+	}
+
+	req := &model.CalculationRequest{
+		TenantID:                tenantID,
+		CalculationInstructions: model.CalculationInstructions{Mutations: muts},
+	}
+	offset := 0
+	if e != nil {
+		req.CalculationInstructions.InitialSituation = &model.InitialSituation{
+			ActualAt:  e.EndSituation.ActualAt,
+			Situation: e.EndSituation.Situation,
+			PolicySeq: e.PolicySeq,
+		}
+		offset = len(e.Mutations)
+	}
+
+	resp := engine.Process(req)
+	if resp.CalculationMetadata.CalculationOutcome != model.OutcomeSuccess {
+		return resp, nil
+	}
+
+	end := resp.CalculationResult.EndSituation
+	if end.Situation.Dossier == nil || end.Situation.Dossier.DossierID != dossierID {
+		return nil, &MutationError{MutationID: muts[0].MutationID, Err: ErrDossierMismatch}
+	}
+
+	// Mutation indexes are stored relative to the dossier's full history.
+	end.MutationIndex += offset
+	end.Situation = end.Situation.Clone()
+	rec := record{
+		TenantID:     tenantID,
+		DossierID:    dossierID,
+		Mutations:    muts,
+		EndSituation: end,
+		PolicySeq:    end.Situation.Dossier.PolicySeq,
+	}
+	if err := s.write(&rec); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.apply(&rec)
+	s.mu.Unlock()
+	return resp, nil
+}
+
+// RewriteFunc receives the stored mutation history of a dossier and returns
+// a corrected history together with its recalculation from an empty
+// situation. Returning a nil history leaves the dossier untouched.
+type RewriteFunc func(history []model.Mutation) ([]model.Mutation, *model.CalculationResponse, error)
+
+// Rewrite replaces the mutation history of a stored dossier. The dossier is
+// locked while fn runs, and the new history is persisted only when its
+// recalculation succeeds.
+func (s *Store) Rewrite(tenantID, dossierID string, fn RewriteFunc) error {
+	k := key{tenantID, dossierID}
+	defer s.lock(k)()
+	e := s.entry(k)
+	if e == nil {
+		return ErrNotFound
+	}
+	muts, resp, err := fn(append([]model.Mutation(nil), e.Mutations...))
+	if err != nil || muts == nil || resp.CalculationMetadata.CalculationOutcome != model.OutcomeSuccess {
+		return err
+	}
+
+	end := resp.CalculationResult.EndSituation
+	if end.Situation.Dossier == nil || end.Situation.Dossier.DossierID != dossierID {
+		return &MutationError{MutationID: end.MutationID, Err: ErrDossierMismatch}
+	}
+	end.Situation = end.Situation.Clone()
+	rec := record{
+		TenantID:     tenantID,
+		DossierID:    dossierID,
+		Replace:      true,
+		Mutations:    muts,
+		EndSituation: end,
+		PolicySeq:    end.Situation.Dossier.PolicySeq,
+	}
+	if err := s.write(&rec); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.apply(&rec)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) write(rec *record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if _, err := s.file.Write(b); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// MutationError wraps a store error with the offending mutation_id.
+type MutationError struct {
+	MutationID string
+	Err        error
+}
+
+func (e *MutationError) Error() string {
+	return e.Err.Error() + ": " + e.MutationID
+}
+
+func (e *MutationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/model"
+	"pension-engine/internal/schemeregistry"
+)
+
+const (
+	tenantID  = "tenant_a"
+	dossierID = "d2222222-2222-2222-2222-222222222222"
+)
+
+func TestAppendPersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := st.Append(tenantID, dossierID, []model.Mutation{createDossierMut("m1"), addPolicyMut("m2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CalculationMetadata.CalculationOutcome != model.OutcomeSuccess {
+		t.Fatalf("expected SUCCESS, got %s", resp.CalculationMetadata.CalculationOutcome)
+	}
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{addPolicyMut("m3")}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	st, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	d, err := st.Get(tenantID, dossierID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Mutations) != 3 {
+		t.Fatalf("expected 3 stored mutations, got %d", len(d.Mutations))
+	}
+	if d.EndSituation.MutationID != "m3" || d.EndSituation.MutationIndex != 2 {
+		t.Fatalf("unexpected end situation envelope: %s/%d", d.EndSituation.MutationID, d.EndSituation.MutationIndex)
+	}
+	if d.EndSituation.PolicySeq != 2 {
+		t.Fatalf("expected the situation to carry policy_seq 2, got %d", d.EndSituation.PolicySeq)
+	}
+
+	// Policy numbering continues from the persisted sequence.
+	resp, err = st.Append(tenantID, dossierID, []model.Mutation{addPolicyMut("m4")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := resp.CalculationResult.EndSituation.Situation.Dossier.Policies
+	if got := policies[len(policies)-1].PolicyID; got != dossierID+"-3" {
+		t.Fatalf("expected policy %s-3, got %s", dossierID, got)
+	}
+}
+
+func TestAppendRejectsReappliedMutation(t *testing.T) {
+	st, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{createDossierMut("m1"), addPolicyMut("m2")}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.Append(tenantID, dossierID, []model.Mutation{addPolicyMut("m2")})
+	if !errors.Is(err, ErrDuplicateMutation) {
+		t.Fatalf("expected ErrDuplicateMutation, got %v", err)
+	}
+
+	d, _ := st.Get(tenantID, dossierID)
+	if len(d.EndSituation.Situation.Dossier.Policies) != 1 {
+		t.Fatal("rejected mutation must not change the stored situation")
+	}
+}
+
+func TestAppendFailureIsNotPersisted(t *testing.T) {
+	st, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	resp, err := st.Append(tenantID, dossierID, []model.Mutation{addPolicyMut("m1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CalculationMetadata.CalculationOutcome != model.OutcomeFailure {
+		t.Fatalf("expected FAILURE, got %s", resp.CalculationMetadata.CalculationOutcome)
+	}
+	if _, err := st.Get(tenantID, dossierID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAppendDoesNotBlockOtherDossiers(t *testing.T) {
+	requested, release := make(chan struct{}, 1), make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		w.Write([]byte(`{"scheme_id":"SCHEME-A","accrual_rate":0.02}`))
+	}))
+	schemeregistry.SetURL(srv.URL)
+	t.Cleanup(func() {
+		unblock()
+		schemeregistry.SetURL("")
+		srv.Close()
+	})
+
+	st, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{createDossierMut("m1"), addPolicyMut("m2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retirement waits for the registry while holding its dossier
+	done := make(chan error, 1)
+	go func() {
+		_, err := st.Append(tenantID, dossierID, []model.Mutation{retirementMut("m3")})
+		done <- err
+	}()
+	<-requested
+
+	other := make(chan error, 1)
+	go func() {
+		_, err := st.Append("tenant_b", dossierID, []model.Mutation{createDossierMut("m1"), addPolicyMut("m2")})
+		other <- err
+	}()
+	// Well within the registry client's timeout
+	select {
+	case err := <-other:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("appending to another dossier waited for the pending calculation")
+	}
+	if _, err := st.Get("tenant_b", dossierID); err != nil {
+		t.Fatalf("expected the other dossier to be stored, got %v", err)
+	}
+
+	unblock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := st.Get(tenantID, dossierID); len(d.Mutations) != 3 {
+		t.Fatalf("expected the retirement to be stored, got %d mutations", len(d.Mutations))
+	}
+}
+
+func TestRewriteReplacesHistory(t *testing.T) {
+	dir := t.TempDir()
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{createDossierMut("m1"), addPolicyMut("m2"), addPolicyMut("m3")}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.Rewrite(tenantID, dossierID, func(history []model.Mutation) ([]model.Mutation, *model.CalculationResponse, error) {
+		muts := history[:2]
+		resp := engine.Process(&model.CalculationRequest{
+			TenantID:                tenantID,
+			CalculationInstructions: model.CalculationInstructions{Mutations: muts},
+		})
+		return muts, resp, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	st, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	d, _ := st.Get(tenantID, dossierID)
+	if len(d.Mutations) != 2 || len(d.EndSituation.Situation.Dossier.Policies) != 1 {
+		t.Fatalf("expected rewritten history of 2 mutations and 1 policy, got %d and %d",
+			len(d.Mutations), len(d.EndSituation.Situation.Dossier.Policies))
+	}
+	// The dropped mutation_id may be applied again after the rewrite.
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{addPolicyMut("m3")}); err != nil {
+		t.Fatalf("expected m3 to be appendable again, got %v", err)
+	}
+}
+
+func TestOpenDiscardsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Append(tenantID, dossierID, []model.Mutation{createDossierMut("m1")}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"tenant_id":"tenant_a","dossier_id":`)
+	f.Close()
+
+	st, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, err := st.Get(tenantID, dossierID); err != nil {
+		t.Fatalf("expected dossier to survive a torn tail, got %v", err)
+	}
+}
+
+func createDossierMut(id string) model.Mutation {
+	return model.Mutation{
+		MutationID:             id,
+		MutationDefinitionName: "create_dossier",
+		MutationType:           "DOSSIER_CREATION",
+		ActualAt:               "2020-01-01",
+		MutationProperties:     json.RawMessage(`{"dossier_id":"` + dossierID + `","person_id":"p1","name":"Jane Doe","birth_date":"1960-06-15"}`),
+	}
+}
+
+func addPolicyMut(id string) model.Mutation {
+	return model.Mutation{
+		MutationID:             id,
+		MutationDefinitionName: "add_policy",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2020-01-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(`{"scheme_id":"SCHEME-A","employment_start_date":"2000-01-01","salary":50000,"part_time_factor":1}`),
+	}
+}
+
+func retirementMut(id string) model.Mutation {
+	return model.Mutation{
+		MutationID:             id,
+		MutationDefinitionName: "calculate_retirement_benefit",
+		MutationType:           "DOSSIER",
+		ActualAt:               "2025-07-01",
+		DossierID:              dossierID,
+		MutationProperties:     json.RawMessage(`{"retirement_date":"2025-07-01"}`),
+	}
+}
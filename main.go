@@ -8,6 +8,8 @@ import (
 	"github.com/valyala/fasthttp"
 
 	"pension-engine/internal/handler"
+	"pension-engine/internal/schemeregistry"
+	"pension-engine/internal/store"
 )
 
 func main() {
@@ -22,8 +24,36 @@ func main() {
 		port = "8080"
 	}
 
+	// DOSSIER_STORE_DIR enables the persistent dossier endpoints.
+	var st *store.Store
+	if dir := os.Getenv("DOSSIER_STORE_DIR"); dir != "" {
+		var err error
+		st, err = store.Open(dir)
+		if err != nil {
+			log.Fatalf("Opening dossier store: %v", err)
+		}
+		defer st.Close()
+		log.Printf("Dossier store opened in %s", dir)
+	}
+
+	// SCHEME_REGISTRY_DIR serves scheme definitions from local files instead
+	// of the registry at SCHEME_REGISTRY_URL.
+	if dir := os.Getenv("SCHEME_REGISTRY_DIR"); dir != "" {
+		schemes, err := schemeregistry.OpenDir(dir)
+		if err != nil {
+			log.Fatalf("Loading scheme registry: %v", err)
+		}
+		defer schemes.Close()
+		schemeregistry.SetDefault(schemes)
+		log.Printf("Scheme registry loaded from %s, version %s", dir, schemes.Version())
+	}
+
+	// ADMIN_TOKEN enables the scheme cache and metrics endpoints for
+	// requests that send it as a bearer token.
+	adminToken := os.Getenv("ADMIN_TOKEN")
+
 	server := &fasthttp.Server{
-		Handler:          handler.HandleCalculation,
+		Handler:          handler.NewRouter(st, adminToken),
 		DisableKeepalive: false,
 		ReadBufferSize:   8192,
 		WriteBufferSize:  8192,
@@ -0,0 +1,8 @@
+// Package definitions embeds the mutation definition documents so the
+// JSON schemas they declare ship inside the binary.
+package definitions
+
+import "embed"
+
+//go:embed *.json
+var FS embed.FS
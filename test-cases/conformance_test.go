@@ -0,0 +1,265 @@
+// Package testcases runs the JSON conformance cases in this directory
+// without a running container: every *.json file is sent through
+// engine.Process and, unless -http=false, through the HTTP handler on an
+// in-memory listener. New case files are picked up automatically.
+package testcases
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	json "github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"pension-engine/internal/engine"
+	"pension-engine/internal/handler"
+	"pension-engine/internal/model"
+)
+
+var viaHTTP = flag.Bool("http", true, "also run every case through the HTTP handler")
+
+// tolerance matches run-tests.sh.
+const tolerance = 0.01
+
+type testCase struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Request     json.RawMessage `json:"request"`
+	Expected    expected        `json:"expected"`
+}
+
+type expected struct {
+	HTTPStatus                int               `json:"http_status"`
+	CalculationOutcome        string            `json:"calculation_outcome"`
+	MessageCount              int               `json:"message_count"`
+	Messages                  []expectedMessage `json:"messages"`
+	EndSituation              json.RawMessage   `json:"end_situation"`
+	EndSituationMutationID    string            `json:"end_situation_mutation_id"`
+	EndSituationMutationIndex int               `json:"end_situation_mutation_index"`
+	EndSituationActualAt      string            `json:"end_situation_actual_at"`
+	MutationsProcessedCount   int               `json:"mutations_processed_count"`
+}
+
+type expectedMessage struct {
+	Level string `json:"level"`
+	Code  string `json:"code"`
+}
+
+func TestConformance(t *testing.T) {
+	cases := loadCases(t)
+
+	var client *fasthttp.Client
+	if *viaHTTP {
+		ln := fasthttputil.NewInmemoryListener()
+		server := &fasthttp.Server{Handler: handler.NewRouter(nil, "")}
+		go server.Serve(ln)
+		defer server.Shutdown()
+		client = &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ID, func(t *testing.T) {
+			t.Run("engine", func(t *testing.T) {
+				var req model.CalculationRequest
+				if err := json.Unmarshal(tc.Request, &req); err != nil {
+					t.Fatalf("decoding request: %v", err)
+				}
+				body, _ := json.Marshal(engine.Process(&req))
+				report(t, tc, check(tc.Expected, 200, body))
+			})
+			if client != nil {
+				t.Run("http", func(t *testing.T) {
+					status, body := post(t, client, tc.Request)
+					report(t, tc, check(tc.Expected, status, body))
+				})
+			}
+		})
+	}
+}
+
+func loadCases(t *testing.T) []testCase {
+	t.Helper()
+	files, err := filepath.Glob("*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test cases found")
+	}
+	sort.Strings(files)
+
+	cases := make([]testCase, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tc testCase
+		if err := json.Unmarshal(b, &tc); err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		if tc.ID == "" {
+			tc.ID = strings.TrimSuffix(f, ".json")
+		}
+		cases = append(cases, tc)
+	}
+	return cases
+}
+
+func post(t *testing.T, client *fasthttp.Client, body []byte) (int, []byte) {
+	t.Helper()
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://engine/calculation-requests")
+	req.Header.SetMethod("POST")
+	req.Header.SetContentType("application/json")
+	req.SetBody(body)
+	if err := client.Do(req, resp); err != nil {
+		t.Fatalf("POST /calculation-requests: %v", err)
+	}
+	return resp.StatusCode(), append([]byte(nil), resp.Body()...)
+}
+
+func report(t *testing.T, tc testCase, diffs []string) {
+	t.Helper()
+	if len(diffs) == 0 {
+		return
+	}
+	t.Errorf("[%s] %s: %d difference(s)\n  - %s", tc.ID, tc.Name, len(diffs), strings.Join(diffs, "\n  - "))
+}
+
+// check compares a response against the expectations the same way
+// run-tests.sh does and returns one line per mismatch.
+func check(exp expected, status int, body []byte) []string {
+	var diffs []string
+	if status != exp.HTTPStatus {
+		diffs = append(diffs, fmt.Sprintf("http_status: expected %d, got %d", exp.HTTPStatus, status))
+	}
+
+	var resp model.CalculationResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return append(diffs, "response is not a CalculationResponse: "+err.Error())
+	}
+	result := resp.CalculationResult
+
+	if got := resp.CalculationMetadata.CalculationOutcome; got != exp.CalculationOutcome {
+		diffs = append(diffs, fmt.Sprintf("calculation_outcome: expected %s, got %s", exp.CalculationOutcome, got))
+	}
+	if got := len(result.Messages); got != exp.MessageCount {
+		diffs = append(diffs, fmt.Sprintf("message_count: expected %d, got %d", exp.MessageCount, got))
+	}
+	for i, m := range exp.Messages {
+		var got expectedMessage
+		if i < len(result.Messages) {
+			got = expectedMessage{Level: result.Messages[i].Level, Code: result.Messages[i].Code}
+		}
+		if got != m {
+			diffs = append(diffs, fmt.Sprintf("messages[%d]: expected %s/%s, got %s/%s", i, m.Level, m.Code, got.Level, got.Code))
+		}
+	}
+
+	end := result.EndSituation
+	if end.MutationID != exp.EndSituationMutationID {
+		diffs = append(diffs, fmt.Sprintf("end_situation_mutation_id: expected %s, got %s", exp.EndSituationMutationID, end.MutationID))
+	}
+	if end.MutationIndex != exp.EndSituationMutationIndex {
+		diffs = append(diffs, fmt.Sprintf("end_situation_mutation_index: expected %d, got %d", exp.EndSituationMutationIndex, end.MutationIndex))
+	}
+	if end.ActualAt != exp.EndSituationActualAt {
+		diffs = append(diffs, fmt.Sprintf("end_situation_actual_at: expected %s, got %s", exp.EndSituationActualAt, end.ActualAt))
+	}
+	if got := len(result.Mutations); got != exp.MutationsProcessedCount {
+		diffs = append(diffs, fmt.Sprintf("mutations_processed_count: expected %d, got %d", exp.MutationsProcessedCount, got))
+	}
+
+	if len(exp.EndSituation) > 0 {
+		var want, got interface{}
+		if err := json.Unmarshal(exp.EndSituation, &want); err != nil {
+			return append(diffs, "expected end_situation is invalid: "+err.Error())
+		}
+		actual, _ := json.Marshal(end.Situation)
+		json.Unmarshal(actual, &got)
+		deepCompare("end_situation", want, got, &diffs)
+	}
+	return diffs
+}
+
+// deepCompare checks every key present in want. Extra keys in got are
+// allowed; numbers match within tolerance and strings case-insensitively.
+func deepCompare(path string, want, got interface{}, diffs *[]string) {
+	switch w := want.(type) {
+	case nil:
+		if got != nil {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected null, got %s", path, render(got)))
+		}
+	case float64:
+		g, ok := got.(float64)
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected number %v, got %s", path, w, render(got)))
+		} else if math.Abs(w-g) > tolerance {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %v, got %v (diff %.4f)", path, w, g, g-w))
+		}
+	case string:
+		g, ok := got.(string)
+		if !ok || !strings.EqualFold(w, g) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %q, got %s", path, w, render(got)))
+		}
+	case bool:
+		if g, ok := got.(bool); !ok || g != w {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %v, got %s", path, w, render(got)))
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected array, got %s", path, render(got)))
+			return
+		}
+		if len(g) != len(w) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %d items, got %d", path, len(w), len(g)))
+			return
+		}
+		for i := range w {
+			deepCompare(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], diffs)
+		}
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected object, got %s", path, render(got)))
+			return
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			gv, present := g[k]
+			if !present {
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: missing", path, k))
+				continue
+			}
+			deepCompare(path+"."+k, w[k], gv, diffs)
+		}
+	}
+}
+
+func render(v interface{}) string {
+	b, _ := json.Marshal(v)
+	if len(b) > 80 {
+		b = append(bytes.Clone(b[:77]), "..."...)
+	}
+	return string(b)
+}